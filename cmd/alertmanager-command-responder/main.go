@@ -14,11 +14,17 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -32,13 +38,34 @@ import (
 	"github.com/prometheus/common/promlog/flag"
 	"github.com/prometheus/common/version"
 	"github.com/treydock/alertmanager-command-responder/internal/alert"
+	"github.com/treydock/alertmanager-command-responder/internal/audit"
+	"github.com/treydock/alertmanager-command-responder/internal/breaker"
 	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/credentials"
+	"github.com/treydock/alertmanager-command-responder/internal/events"
+	"github.com/treydock/alertmanager-command-responder/internal/input"
+	"github.com/treydock/alertmanager-command-responder/internal/maintenance"
 	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/pause"
+	"github.com/treydock/alertmanager-command-responder/internal/poll"
+	"github.com/treydock/alertmanager-command-responder/internal/state"
+	"github.com/treydock/alertmanager-command-responder/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	configPath = kingpin.Flag("config.file", "path to configuration file").Default("alertmanager-command-responder.yaml").String()
-	listenAddr = kingpin.Flag("web.listen-address", "HTTP port to listen on").Default(":10000").String()
+	serveCmd        = kingpin.Command("serve", "Run the command responder").Default()
+	checkConfigCmd  = kingpin.Command("check-config", "Validate the configuration file and exit")
+	configPath      = kingpin.Flag("config.file", "path to configuration file").Default("alertmanager-command-responder.yaml").String()
+	listenAddr      = kingpin.Flag("web.listen-address", "HTTP port to listen on").Default(":10000").String()
+	dryRun          = kingpin.Flag("dry-run", "Resolve commands for alerts but do not execute them").Default("false").Bool()
+	syncTimeout     = kingpin.Flag("web.sync-timeout", "Maximum time to wait for alerts to be handled when using synchronous mode").Default("30s").Duration()
+	enableLifecycle = kingpin.Flag("web.enable-lifecycle", "Enable reloading the configuration via HTTP request").Default("false").Bool()
+	watchConfig     = kingpin.Flag("config.watch", "Reload the configuration when the configuration file or files it references change").Default("false").Bool()
 )
 
 func init() {
@@ -51,6 +78,19 @@ type Version struct {
 	BuildDate string `json:"builddate"`
 }
 
+type AlertResult struct {
+	Fingerprint string                `json:"fingerprint"`
+	Name        string                `json:"alertname"`
+	Status      string                `json:"status"`
+	Completed   bool                  `json:"completed"`
+	Skipped     string                `json:"skipped,omitempty"`
+	Error       string                `json:"error,omitempty"`
+	Results     []alert.CommandResult `json:"results"`
+	SilenceID   string                `json:"silence_id,omitempty"`
+	Alerts      []string              `json:"alerts,omitempty"`
+	Level       int                   `json:"level,omitempty"`
+}
+
 type JSONResponse struct {
 	Status     string      `json:"status"`
 	StatusCode int         `json:"statusCode"`
@@ -90,30 +130,449 @@ func notFound(w http.ResponseWriter, r *http.Request) {
 	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK, Data: s.Alerts, logger: s.Logger})
 }*/
 
-func postAlertHandler(w http.ResponseWriter, r *http.Request, c *config.Config, logger log.Logger) {
+// alertmanagerInput is the input of the /alerts endpoint.
+var alertmanagerInput = config.Input{Name: "alertmanager", Type: "alertmanager"}
+
+func postAlertHandler(w http.ResponseWriter, r *http.Request, in config.Input, c *config.Config, logger log.Logger) {
 	defer r.Body.Close()
-	var data template.Data
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracing.Tracer().Start(ctx, "postAlertHandler", trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("input", in.Name)))
+	defer span.End()
+	logger = log.With(logger, "input", in.Name)
+	data, err := input.Decode(in, r.Body)
+	if err != nil {
 		level.Error(logger).Log("msg", "error decoding message", "err", err)
+		span.SetStatus(codes.Error, err.Error())
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	wait, timeout, err := syncParams(r)
+	if err != nil {
+		level.Error(logger).Log("msg", "error parsing query parameters", "err", err)
 		metrics.ErrorsTotal.Inc()
 		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusBadRequest, Message: err.Error()})
 		return
 	}
-	level.Info(logger).Log("msg", fmt.Sprintf("Received %d alerts", len(data.Alerts)))
-	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusCreated})
+	span.SetAttributes(attribute.Int("alerts", len(data.Alerts)), attribute.Bool("sync", wait))
+	level.Info(logger).Log("msg", fmt.Sprintf("Received %d alerts", len(data.Alerts)), "sync", wait)
+	if data.TruncatedAlerts > 0 {
+		level.Warn(logger).Log("msg", "Alertmanager truncated alerts from notification, increase max_alerts of the webhook receiver",
+			"truncated", data.TruncatedAlerts, "receiver", data.Receiver, "group_key", data.GroupKey)
+		metrics.TruncatedAlertsTotal.WithLabelValues(data.Receiver).Add(float64(data.TruncatedAlerts))
+		span.SetAttributes(attribute.Int64("truncated_alerts", int64(data.TruncatedAlerts)))
+	}
+	if !wait {
+		asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusCreated})
+	}
+	alerts := make([]alert.Alert, len(data.Alerts))
+	source := alert.Source{
+		RemoteAddr:        remoteAddr(r),
+		Receiver:          data.Receiver,
+		GroupKey:          data.GroupKey,
+		GroupLabels:       data.GroupLabels,
+		CommonLabels:      data.CommonLabels,
+		CommonAnnotations: data.CommonAnnotations,
+		ExternalURL:       data.ExternalURL,
+		TruncatedAlerts:   data.TruncatedAlerts,
+	}
+	for i, a := range data.Alerts {
+		alerts[i] = alert.Alert{
+			Alert:  a,
+			Source: source,
+		}
+	}
+	alerts = alert.GroupAlerts(alerts)
+	handleAlerts(ctx, w, alerts, c, logger, wait, timeout)
+}
 
-	for _, a := range data.Alerts {
-		go func(a template.Alert) {
-			newAlert := alert.Alert{
-				Alert: a,
-			}
-			err := newAlert.HandleAlert(c, logger)
+type RunRequest struct {
+	Status      string      `json:"status"`
+	Labels      template.KV `json:"labels"`
+	Annotations template.KV `json:"annotations"`
+	DryRun      bool        `json:"dry_run"`
+}
+
+func runResponderHandler(w http.ResponseWriter, r *http.Request, c *config.Config, logger log.Logger) {
+	defer r.Body.Close()
+	if !authorized(r, c) {
+		level.Error(logger).Log("msg", "Unauthorized request to run responder", "remote", r.RemoteAddr)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		level.Error(logger).Log("msg", "error decoding message", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	_, timeout, err := syncParams(r)
+	if err != nil {
+		level.Error(logger).Log("msg", "error parsing query parameters", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	name := mux.Vars(r)["name"]
+	a := alert.NewManualAlert(name, req.Status, req.Labels, req.Annotations)
+	a.DryRun = req.DryRun
+	a.Source = alert.Source{RemoteAddr: remoteAddr(r)}
+	level.Info(logger).Log("msg", "Running responder", "responder", name, "dry_run", a.DryRun, "remote", r.RemoteAddr)
+	handleAlerts(r.Context(), w, []alert.Alert{a}, c, logger, true, timeout)
+}
+
+func listMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK, Data: maintenance.List()})
+}
+
+func addMaintenanceHandler(w http.ResponseWriter, r *http.Request, c *config.Config, logger log.Logger) {
+	defer r.Body.Close()
+	if !authorized(r, c) {
+		level.Error(logger).Log("msg", "Unauthorized request to add maintenance window", "remote", r.RemoteAddr)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+	var window maintenance.Window
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		level.Error(logger).Log("msg", "error decoding message", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	if window.CreatedBy == "" {
+		window.CreatedBy = remoteAddr(r)
+	}
+	window, err := maintenance.Add(window)
+	if err != nil {
+		level.Error(logger).Log("msg", "Unable to add maintenance window", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	level.Info(logger).Log("msg", "Added maintenance window", "id", window.ID, "matchers", strings.Join(window.Matchers, ","), "remote", r.RemoteAddr)
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK, Data: window})
+}
+
+func deleteMaintenanceHandler(w http.ResponseWriter, r *http.Request, c *config.Config, logger log.Logger) {
+	if !authorized(r, c) {
+		level.Error(logger).Log("msg", "Unauthorized request to delete maintenance window", "remote", r.RemoteAddr)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+	id := mux.Vars(r)["id"]
+	ok, err := maintenance.Delete(id)
+	if err != nil {
+		level.Error(logger).Log("msg", "Unable to save maintenance windows", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusInternalServerError, Message: err.Error()})
+		return
+	} else if !ok {
+		notFound(w, r)
+		return
+	}
+	level.Info(logger).Log("msg", "Deleted maintenance window", "id", id, "remote", r.RemoteAddr)
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK})
+}
+
+func pauseStatusHandler(w http.ResponseWriter, r *http.Request) {
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK, Data: pause.Get()})
+}
+
+// pauseHandler pauses or resumes the responder in the path, or all responders if there is no responder.
+func pauseHandler(w http.ResponseWriter, r *http.Request, c *config.Config, logger log.Logger, paused bool) {
+	action := "resume"
+	if paused {
+		action = "pause"
+	}
+	if !authorized(r, c) {
+		level.Error(logger).Log("msg", "Unauthorized request to "+action, "remote", r.RemoteAddr)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+	responder := mux.Vars(r)["responder"]
+	var err error
+	if paused {
+		err = pause.Pause(responder, remoteAddr(r))
+	} else {
+		err = pause.Resume(responder)
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "Unable to save paused state", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusInternalServerError, Message: err.Error()})
+		return
+	}
+	if paused {
+		level.Warn(logger).Log("msg", "Paused commands", "responder", responder, "remote", r.RemoteAddr)
+	} else {
+		level.Info(logger).Log("msg", "Resumed commands", "responder", responder, "remote", r.RemoteAddr)
+	}
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK, Data: pause.Get()})
+}
+
+func listBreakersHandler(w http.ResponseWriter, r *http.Request) {
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK, Data: breaker.List()})
+}
+
+func resetBreakerHandler(w http.ResponseWriter, r *http.Request, c *config.Config, logger log.Logger) {
+	if !authorized(r, c) {
+		level.Error(logger).Log("msg", "Unauthorized request to reset circuit breaker", "remote", r.RemoteAddr)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusUnauthorized, Message: "unauthorized"})
+		return
+	}
+	responder := mux.Vars(r)["responder"]
+	ok, err := breaker.Reset(responder)
+	if err != nil {
+		level.Error(logger).Log("msg", "Unable to save circuit breakers", "err", err)
+		metrics.ErrorsTotal.Inc()
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusInternalServerError, Message: err.Error()})
+		return
+	} else if !ok {
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusNotFound, Message: "circuit breaker has not tripped"})
+		return
+	}
+	level.Info(logger).Log("msg", "Reset circuit breaker", "responder", responder, "remote", r.RemoteAddr)
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK})
+}
+
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// warnAPIToken logs that the APIs that require api_token are disabled when it is not set.
+func warnAPIToken(c *config.Config, logger log.Logger) {
+	if c.APIToken == "" {
+		level.Warn(logger).Log("msg", "api_token is not set, the manual run, maintenance, pause and circuit breaker APIs are disabled")
+	}
+}
+
+func authorized(r *http.Request, c *config.Config) bool {
+	if c.APIToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.APIToken)) == 1
+}
+
+// handleAlerts handles each alert in a goroutine that is not canceled when the request completes.
+// The trace of ctx is kept so the spans of each alert are children of the request span.
+// The response is only written when waiting for the alerts to be handled.
+func handleAlerts(ctx context.Context, w http.ResponseWriter, alerts []alert.Alert, c *config.Config, logger log.Logger, wait bool, timeout time.Duration) {
+	ctx = trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	results := make(chan int, len(alerts))
+	errs := make([]error, len(alerts))
+	response := make([]AlertResult, len(alerts))
+	for i := range alerts {
+		response[i] = AlertResult{
+			Fingerprint: alerts[i].Fingerprint,
+			Name:        alerts[i].Name(),
+			Status:      alerts[i].Status,
+		}
+		for _, a := range alerts[i].Alerts {
+			response[i].Alerts = append(response[i].Alerts, a.Fingerprint)
+		}
+		alerts[i].DryRun = alerts[i].DryRun || *dryRun
+		go func(i int) {
+			err := alerts[i].HandleAlert(ctx, c, logger)
 			if err != nil {
-				level.Error(logger).Log("msg", "Error handling alert", "err", err, "fingerprint", a.Fingerprint)
+				level.Error(logger).Log("msg", "Error handling alert", "err", err, "fingerprint", alerts[i].Fingerprint)
 				metrics.ErrorsTotal.Inc()
 			}
-		}(a)
+			errs[i] = err
+			results <- i
+		}(i)
+	}
+	if !wait {
+		return
+	}
+
+	status := "success"
+	statusCode := http.StatusOK
+	deadline := time.After(timeout)
+	for remaining := len(alerts); remaining > 0; remaining-- {
+		select {
+		case i := <-results:
+			response[i].Completed = true
+			response[i].Skipped = alerts[i].Skipped
+			response[i].Results = alerts[i].Results
+			response[i].SilenceID = alerts[i].SilenceID
+			response[i].Level = alerts[i].Level
+			if errs[i] != nil {
+				response[i].Error = errs[i].Error()
+				status = "error"
+				statusCode = http.StatusInternalServerError
+			}
+		case <-deadline:
+			level.Error(logger).Log("msg", "Timeout waiting for alerts to be handled", "timeout", timeout, "pending", remaining)
+			asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusGatewayTimeout,
+				Message: fmt.Sprintf("Timeout waiting for %d alerts", remaining), Data: response, logger: logger})
+			return
+		}
+	}
+	asJSON(w, JSONResponse{Status: status, StatusCode: statusCode, Data: response, logger: logger})
+}
+
+func syncParams(r *http.Request) (bool, time.Duration, error) {
+	var wait bool
+	var err error
+	timeout := *syncTimeout
+	if val := r.URL.Query().Get("wait"); val != "" {
+		wait, err = strconv.ParseBool(val)
+		if err != nil {
+			return false, timeout, fmt.Errorf("Unable to parse wait: %s", val)
+		}
+	}
+	if val := r.URL.Query().Get("timeout"); val != "" {
+		t, err := time.ParseDuration(val)
+		if err != nil {
+			return false, timeout, fmt.Errorf("Unable to parse timeout: %s", val)
+		}
+		if t < timeout {
+			timeout = t
+		}
+	}
+	return wait, timeout, nil
+}
+
+func reloadHandler(w http.ResponseWriter, r *http.Request, sc *config.SafeConfig, logger log.Logger) {
+	if !*enableLifecycle {
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusForbidden, Message: "Lifecycle API is not enabled"})
+		return
+	}
+	if err := reloadConfig(sc, logger); err != nil {
+		asJSON(w, JSONResponse{Status: "error", StatusCode: http.StatusInternalServerError, Message: err.Error(), logger: logger})
+		return
+	}
+	asJSON(w, JSONResponse{Status: "success", StatusCode: http.StatusOK})
+}
+
+// component is a package configured from the configuration at startup and on reload.
+type component struct {
+	name      string
+	configure func(c *config.Config) error
+}
+
+var components = []component{
+	{"event sinks", func(c *config.Config) error { return events.Configure(c.EventSinks) }},
+	{"tracing", func(c *config.Config) error { return tracing.Configure(c.Tracing) }},
+	{"audit log", func(c *config.Config) error { return audit.Configure(c.AuditLog, []byte(c.AuditKey)) }},
+	{"state", func(c *config.Config) error { return state.Configure(c.StateFile, c.StateMaxAge) }},
+	{"maintenance windows", func(c *config.Config) error { return maintenance.Configure(c.MaintenanceFile) }},
+	{"paused state", func(c *config.Config) error { return pause.Configure(c.PauseFile) }},
+	{"circuit breakers", func(c *config.Config) error { return breaker.Configure(c.BreakerFile) }},
+}
+
+// configureComponents configures every component from c. If a component fails the components
+// already configured are configured again from old so they match the configuration still in use.
+func configureComponents(c *config.Config, old *config.Config, logger log.Logger) error {
+	for i, comp := range components {
+		if err := comp.configure(c); err != nil {
+			if old != nil {
+				for j := i - 1; j >= 0; j-- {
+					if rollbackErr := components[j].configure(old); rollbackErr != nil {
+						level.Error(logger).Log("msg", "Failed to restore component", "component", components[j].name, "err", rollbackErr)
+					}
+				}
+			}
+			return fmt.Errorf("Failed to configure %s: %v", comp.name, err)
+		}
 	}
+	return nil
+}
+
+var (
+	pollerLock    sync.Mutex
+	poller        *poll.Poller
+	pollerDone    chan struct{}
+	pollerStopped chan struct{}
+)
+
+// newPoller returns the poller for the configuration or nil if polling is disabled.
+// The running poller is kept if its state file has not changed so the alerts it tracks are not loaded again.
+func newPoller(sc *config.SafeConfig, c *config.Config, logger log.Logger) (*poll.Poller, error) {
+	if c.Alertmanager.Poll.Interval <= 0 {
+		return nil, nil
+	}
+	pollerLock.Lock()
+	running := poller
+	pollerLock.Unlock()
+	if running != nil && running.StateFile() == c.Alertmanager.Poll.StateFile {
+		return running, nil
+	}
+	return poll.New(sc, c.Alertmanager.Poll.StateFile, func(alerts []alert.Alert) {
+		handleAlerts(context.Background(), nil, alert.GroupAlerts(alerts), sc.Config(), logger, false, 0)
+	}, logger)
+}
+
+// startPoller runs p, the running poller is stopped first if it is not p. A nil p stops polling.
+func startPoller(p *poll.Poller) {
+	pollerLock.Lock()
+	defer pollerLock.Unlock()
+	if poller == p {
+		return
+	}
+	if poller != nil {
+		close(pollerDone)
+		<-pollerStopped
+	}
+	poller = p
+	if p == nil {
+		return
+	}
+	pollerDone = make(chan struct{})
+	pollerStopped = make(chan struct{})
+	go func(done chan struct{}, stopped chan struct{}) {
+		p.Run(done)
+		close(stopped)
+	}(pollerDone, pollerStopped)
+}
+
+// reloadLock serializes reloads from SIGHUP, the config watcher and the lifecycle API
+// so the components, poller and config are always from the same reload.
+var reloadLock sync.Mutex
+
+func reloadConfig(sc *config.SafeConfig, logger log.Logger) error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	c, err := sc.LoadConfig()
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to load configuration file, using old config.", "err", err)
+		metrics.ErrorsTotal.Inc()
+		metrics.ConfigLastReloadSuccessful.Set(0)
+		return err
+	}
+	if err := configureComponents(c, sc.Config(), logger); err != nil {
+		level.Error(logger).Log("msg", "Failed to apply configuration, using old config.", "err", err)
+		metrics.ErrorsTotal.Inc()
+		metrics.ConfigLastReloadSuccessful.Set(0)
+		return err
+	}
+	p, err := newPoller(sc, c, logger)
+	if err != nil {
+		_ = configureComponents(sc.Config(), nil, logger)
+		level.Error(logger).Log("msg", "Failed to start polling Alertmanager, using old config.", "err", err)
+		metrics.ErrorsTotal.Inc()
+		metrics.ConfigLastReloadSuccessful.Set(0)
+		return err
+	}
+	sc.SetConfig(c)
+	startPoller(p)
+	credentials.Reset()
+	warnAPIToken(c, logger)
+	metrics.ConfigLastReloadSuccessful.Set(1)
+	metrics.ConfigLastReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
 }
 
 func metricsHandler(w http.ResponseWriter, r *http.Request) {
@@ -131,11 +590,7 @@ func run(sc *config.SafeConfig, logger log.Logger) int {
 			sig := <-signal_chan
 			switch sig {
 			case syscall.SIGHUP:
-				err := sc.ReadConfig()
-				if err != nil {
-					level.Error(logger).Log("msg", "Failed to load configuration file, using old config.")
-					metrics.ErrorsTotal.Inc()
-				}
+				_ = reloadConfig(sc, logger)
 			case syscall.SIGINT:
 				exit_chan <- 0
 			case syscall.SIGTERM:
@@ -149,14 +604,73 @@ func run(sc *config.SafeConfig, logger log.Logger) int {
 		}
 	}()
 
+	if *watchConfig {
+		done := make(chan struct{})
+		defer close(done)
+		err := sc.Watch(done, func() {
+			_ = reloadConfig(sc, logger)
+		})
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to watch configuration files", "err", err)
+			return 1
+		}
+	}
+
+	if c := sc.Config(); c != nil {
+		p, err := newPoller(sc, c, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to start polling Alertmanager", "err", err)
+			return 1
+		}
+		startPoller(p)
+		defer startPoller(nil)
+	}
+
 	r := mux.NewRouter()
 	r.HandleFunc("/healthz", healthzHandler).Methods(http.MethodGet)
 	r.HandleFunc("/version", versionHandler).Methods(http.MethodGet)
 	r.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
-		configHandler(w, r, sc.C)
+		configHandler(w, r, sc.Config())
 	}).Methods(http.MethodGet)
 	r.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
-		postAlertHandler(w, r, sc.C, logger)
+		postAlertHandler(w, r, alertmanagerInput, sc.Config(), logger)
+	}).Methods(http.MethodPost)
+	r.HandleFunc("/inputs/{name}", func(w http.ResponseWriter, r *http.Request) {
+		c := sc.Config()
+		in, ok := c.Input(mux.Vars(r)["name"])
+		if !ok {
+			notFound(w, r)
+			return
+		}
+		postAlertHandler(w, r, in, c, logger)
+	}).Methods(http.MethodPost)
+	r.HandleFunc("/responders/{name}/run", func(w http.ResponseWriter, r *http.Request) {
+		runResponderHandler(w, r, sc.Config(), logger)
+	}).Methods(http.MethodPost)
+	r.HandleFunc("/maintenance", listMaintenanceHandler).Methods(http.MethodGet)
+	r.HandleFunc("/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		addMaintenanceHandler(w, r, sc.Config(), logger)
+	}).Methods(http.MethodPost)
+	r.HandleFunc("/maintenance/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deleteMaintenanceHandler(w, r, sc.Config(), logger)
+	}).Methods(http.MethodDelete)
+	r.HandleFunc("/-/pause", pauseStatusHandler).Methods(http.MethodGet)
+	for _, path := range []string{"/-/pause", "/-/pause/{responder}"} {
+		r.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			pauseHandler(w, r, sc.Config(), logger, true)
+		}).Methods(http.MethodPost)
+	}
+	for _, path := range []string{"/-/resume", "/-/resume/{responder}"} {
+		r.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			pauseHandler(w, r, sc.Config(), logger, false)
+		}).Methods(http.MethodPost)
+	}
+	r.HandleFunc("/-/breakers", listBreakersHandler).Methods(http.MethodGet)
+	r.HandleFunc("/-/breakers/{responder}/reset", func(w http.ResponseWriter, r *http.Request) {
+		resetBreakerHandler(w, r, sc.Config(), logger)
+	}).Methods(http.MethodPost)
+	r.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		reloadHandler(w, r, sc, logger)
 	}).Methods(http.MethodPost)
 	r.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
 		metricsHandler(w, r)
@@ -166,7 +680,7 @@ func run(sc *config.SafeConfig, logger log.Logger) int {
 		Handler:      r,
 		Addr:         *listenAddr,
 		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
+		WriteTimeout: *syncTimeout + 3*time.Second,
 	}
 	go func() {
 		if err := srv.ListenAndServe(); err != nil {
@@ -177,24 +691,59 @@ func run(sc *config.SafeConfig, logger log.Logger) int {
 
 	code := <-exit_chan
 	level.Info(logger).Log("msg", "Shutting down")
+	if err := tracing.Shutdown(); err != nil {
+		level.Error(logger).Log("msg", "Error shutting down tracing", "err", err)
+	}
 	return code
 }
 
+func checkConfig(sc *config.SafeConfig) int {
+	errs := sc.CheckConfig()
+	if len(errs) == 0 {
+		fmt.Printf("Configuration file %s is valid\n", *configPath)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "Configuration file %s has %d errors:\n", *configPath, len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  - %s\n", err)
+	}
+	return 1
+}
+
 func main() {
 	promlogConfig := &promlog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promlogConfig)
 	kingpin.Version(version.Print("alertmanager-command-responder"))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	cmd := kingpin.Parse()
 
 	logger := promlog.New(promlogConfig)
 	sc := config.NewSafeConfig(*configPath, logger)
-	err := sc.ReadConfig()
-	if err != nil {
-		level.Error(logger).Log("msg", "Failed to load configuration file, exiting.")
-		os.Exit(1)
+	switch cmd {
+	case checkConfigCmd.FullCommand():
+		os.Exit(checkConfig(sc))
+	case verifyAuditCmd.FullCommand():
+		os.Exit(runVerifyAudit(sc))
+	case simulateCmd.FullCommand():
+		if err := sc.ReadConfig(); err != nil {
+			level.Error(logger).Log("msg", "Failed to load configuration file, exiting.")
+			os.Exit(1)
+		}
+		os.Exit(runSimulate(sc, logger))
+	case serveCmd.FullCommand():
+		err := sc.ReadConfig()
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load configuration file, exiting.")
+			os.Exit(1)
+		}
+		if err := configureComponents(sc.Config(), nil, logger); err != nil {
+			level.Error(logger).Log("msg", "Failed to apply configuration, exiting.", "err", err)
+			os.Exit(1)
+		}
+		warnAPIToken(sc.Config(), logger)
+		metrics.ConfigLastReloadSuccessful.Set(1)
+		metrics.ConfigLastReloadSuccessTimestamp.SetToCurrentTime()
+		e := run(sc, logger)
+		os.Exit(e)
 	}
-
-	e := run(sc, logger)
-	os.Exit(e)
 }
@@ -17,10 +17,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -28,9 +32,15 @@ import (
 	"github.com/go-kit/log"
 	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/treydock/alertmanager-command-responder/internal/audit"
 	"github.com/treydock/alertmanager-command-responder/internal/config"
 	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/pause"
 	"github.com/treydock/alertmanager-command-responder/internal/utils"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var sshPort = 2221
@@ -60,12 +70,11 @@ func TestRun(t *testing.T) {
 	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
 		t.Fatal(err)
 	}
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser: "test",
-			SSHKey:  filepath.Join(FixtureDir(), "id_rsa_test1"),
-		},
-	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+		SSHKey:  filepath.Join(FixtureDir(), "id_rsa_test1"),
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	tmp, err := os.CreateTemp("", "file")
@@ -79,6 +88,7 @@ func TestRun(t *testing.T) {
 		t.Errorf("Unable to close temp file: %s", err)
 	}
 	go run(sc, logger)
+	waitForServer(t, port)
 
 	data := template.Data{
 		Alerts: []template.Alert{
@@ -122,8 +132,8 @@ func TestRun(t *testing.T) {
 	}
 
 	// Test setting ssh_key and ssh_user via annotation
-	sc.C.SSHUser = ""
-	sc.C.SSHKey = ""
+	sc.Config().SSHUser = ""
+	sc.Config().SSHKey = ""
 	data = template.Data{
 		Alerts: []template.Alert{
 			template.Alert{
@@ -177,15 +187,15 @@ func TestRunStatus(t *testing.T) {
 	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
 		t.Fatal(err)
 	}
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser: "test",
-			SSHKey:  filepath.Join(FixtureDir(), "id_rsa_test1"),
-		},
-	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+		SSHKey:  filepath.Join(FixtureDir(), "id_rsa_test1"),
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	go run(sc, logger)
+	waitForServer(t, port)
 
 	data := template.Data{
 		Alerts: []template.Alert{
@@ -281,15 +291,15 @@ func TestRunPassword(t *testing.T) {
 	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
 		t.Fatal(err)
 	}
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser:     "test",
-			SSHPassword: "test",
-		},
-	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:     "test",
+		SSHPassword: "test",
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	go run(sc, logger)
+	waitForServer(t, port)
 
 	data := template.Data{
 		Alerts: []template.Alert{
@@ -322,7 +332,7 @@ func TestRunPassword(t *testing.T) {
 	TestLock.Unlock()
 
 	// Test incorrect password
-	sc.C.SSHPassword = "wrong"
+	sc.Config().SSHPassword = "wrong"
 	jsonData, err = json.Marshal(data)
 	if err != nil {
 		t.Errorf("Unexpected error generating JSON data: %s", err)
@@ -346,16 +356,16 @@ func TestRunCert(t *testing.T) {
 	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
 		t.Fatal(err)
 	}
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser:        "test",
-			SSHKey:         filepath.Join(FixtureDir(), "id_rsa_test1"),
-			SSHCertificate: filepath.Join(FixtureDir(), "id_rsa_test1-cert.pub"),
-		},
-	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:        "test",
+		SSHKey:         filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCertificate: filepath.Join(FixtureDir(), "id_rsa_test1-cert.pub"),
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	go run(sc, logger)
+	waitForServer(t, port)
 
 	data := template.Data{
 		Alerts: []template.Alert{
@@ -388,8 +398,8 @@ func TestRunCert(t *testing.T) {
 	TestLock.Unlock()
 
 	// Test setting ssh_certificate via annotation
-	sc.C.SSHKey = ""
-	sc.C.SSHCertificate = ""
+	sc.Config().SSHKey = ""
+	sc.Config().SSHCertificate = ""
 	data = template.Data{
 		Alerts: []template.Alert{
 			template.Alert{
@@ -433,6 +443,7 @@ func TestRunGET(t *testing.T) {
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	go run(sc, logger)
+	waitForServer(t, port)
 	time.Sleep(2 * time.Second)
 	resp, err := http.Get(fmt.Sprintf("http://localhost:%s/healthz", port))
 	if err != nil {
@@ -462,14 +473,14 @@ func TestRunMetrics(t *testing.T) {
 	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
 		t.Fatal(err)
 	}
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser: "test",
-		},
-	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	go run(sc, logger)
+	waitForServer(t, port)
 	data := template.Data{
 		Alerts: []template.Alert{
 			template.Alert{
@@ -624,6 +635,7 @@ func TestRunInvalidJSON(t *testing.T) {
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	go run(sc, logger)
+	waitForServer(t, port)
 	time.Sleep(2 * time.Second)
 	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts", port), "application/json", bytes.NewBuffer([]byte("foo")))
 	if err != nil {
@@ -634,6 +646,1319 @@ func TestRunInvalidJSON(t *testing.T) {
 	}
 }
 
+func TestRunSync(t *testing.T) {
+	port := "10008"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:             "test",
+		SSHKey:              filepath.Join(FixtureDir(), "id_rsa_test1"),
+		LocalCommandTimeout: 2 * time.Second,
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	data := template.Data{
+		Alerts: []template.Alert{
+			template.Alert{
+				Status: "firing",
+				Annotations: template.KV{
+					"cr_ssh_host":        fmt.Sprintf("localhost:%d", sshPort),
+					"cr_ssh_cmd":         "test0.0",
+					"cr_ssh_cmd_timeout": "2s",
+				},
+				Fingerprint: "test-sync-ssh",
+			},
+			template.Alert{
+				Status: "firing",
+				Annotations: template.KV{
+					"cr_local_cmd": "echo sync",
+				},
+				Fingerprint: "test-sync-local",
+			},
+			template.Alert{
+				Status: "resolved",
+				Annotations: template.KV{
+					"cr_local_cmd": "echo resolved",
+				},
+				Fingerprint: "test-sync-skipped",
+			},
+		},
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response struct {
+		Data []AlertResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	if len(response.Data) != 3 {
+		t.Fatalf("Unexpected number of results, got %d", len(response.Data))
+	}
+	for _, r := range response.Data {
+		if !r.Completed {
+			t.Errorf("Alert %s was not completed", r.Fingerprint)
+		}
+	}
+	if len(response.Data[0].Results) != 1 || response.Data[0].Results[0].Type != "ssh" {
+		t.Errorf("Unexpected SSH results, got %+v", response.Data[0].Results)
+	}
+	if len(response.Data[1].Results) != 1 || response.Data[1].Results[0].Stdout != "sync\n" {
+		t.Errorf("Unexpected local results, got %+v", response.Data[1].Results)
+	}
+	if response.Data[2].Skipped != "status" || len(response.Data[2].Results) != 0 {
+		t.Errorf("Expected resolved alert to be skipped, got %+v", response.Data[2])
+	}
+	TestLock.Lock()
+	TestResults["test0.0"] = false
+	TestLock.Unlock()
+
+	// Test command error and timeout
+	data = template.Data{
+		Alerts: []template.Alert{
+			template.Alert{
+				Status: "firing",
+				Annotations: template.KV{
+					"cr_local_cmd": "exit 1",
+				},
+				Fingerprint: "test-sync-error",
+			},
+		},
+	}
+	jsonData, err = json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+	data.Alerts[0].Annotations = template.KV{"cr_local_cmd": "sleep 2"}
+	jsonData, err = json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true&timeout=500ms", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected status code %d got %d", http.StatusGatewayTimeout, resp.StatusCode)
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=foo", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestRunResponder(t *testing.T) {
+	port := "10009"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:  "test",
+		SSHKey:   filepath.Join(FixtureDir(), "id_rsa_test1"),
+		APIToken: "secret",
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	req := RunRequest{
+		Labels: template.KV{"host": "localhost"},
+		Annotations: template.KV{
+			"cr_ssh_host":        fmt.Sprintf("localhost:%d", sshPort),
+			"cr_ssh_cmd":         "test7",
+			"cr_ssh_cmd_timeout": "2s",
+		},
+	}
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	url := fmt.Sprintf("http://localhost:%s/responders/restart/run", port)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	// The token is not exposed by the config endpoint
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%s/config", port))
+	if err != nil {
+		t.Fatalf("Unexpected error making GET request: %s", err)
+	}
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if strings.Contains(body.String(), `"api_token":"secret"`) {
+		t.Errorf("Unexpected config: %s", body.String())
+	}
+
+	post := func(body []byte) (*http.Response, []AlertResult) {
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Unexpected error creating request: %s", err)
+		}
+		request.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Unexpected error making POST request: %s", err)
+		}
+		defer resp.Body.Close()
+		var response struct {
+			Data []AlertResult `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Unexpected error decoding response: %s", err)
+		}
+		return resp, response.Data
+	}
+
+	resp, data := post(jsonData)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if len(data) != 1 || data[0].Name != "restart" || len(data[0].Results) != 1 {
+		t.Fatalf("Unexpected response data: %+v", data)
+	}
+	TestLock.Lock()
+	if !TestResults["test7"] {
+		t.Errorf("Test7 was not executed")
+	}
+	TestResults["test7"] = false
+	TestLock.Unlock()
+
+	// Test dry run
+	req.DryRun = true
+	jsonData, err = json.Marshal(req)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, data = post(jsonData)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if len(data) != 1 || len(data[0].Results) != 1 || !data[0].Results[0].DryRun {
+		t.Fatalf("Unexpected response data: %+v", data)
+	}
+	TestLock.Lock()
+	if TestResults["test7"] {
+		t.Errorf("Test7 was executed during dry run")
+	}
+	TestLock.Unlock()
+}
+
+func TestRunDryRun(t *testing.T) {
+	port := "10010"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port), "--dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		*dryRun = false
+	}()
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+		SSHKey:  filepath.Join(FixtureDir(), "id_rsa_test1"),
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	data := template.Data{
+		Alerts: []template.Alert{
+			template.Alert{
+				Status: "firing",
+				Annotations: template.KV{
+					"cr_ssh_host":        fmt.Sprintf("localhost:%d", sshPort),
+					"cr_ssh_cmd":         "test8",
+					"cr_ssh_cmd_timeout": "2s",
+					"cr_local_cmd":       "hostname",
+				},
+				Fingerprint: "test-dry-run",
+			},
+		},
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	metrics.DryRunsTotal.Reset()
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	defer resp.Body.Close()
+	var response struct {
+		Data []AlertResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	if len(response.Data) != 1 || len(response.Data[0].Results) != 2 {
+		t.Fatalf("Unexpected response data: %+v", response.Data)
+	}
+	for _, r := range response.Data[0].Results {
+		if !r.DryRun {
+			t.Errorf("Expected dry run result, got %+v", r)
+		}
+	}
+	if auth := response.Data[0].Results[1].Auth; auth != "key" {
+		t.Errorf("Unexpected auth, got %s", auth)
+	}
+	TestLock.Lock()
+	if TestResults["test8"] {
+		t.Errorf("Test8 was executed during dry run")
+	}
+	TestLock.Unlock()
+	expected := `
+	# HELP alertmanager_command_responder_dry_runs_total Total number of commands not executed because of dry run
+	# TYPE alertmanager_command_responder_dry_runs_total counter
+	alertmanager_command_responder_dry_runs_total{type="local"} 1
+	alertmanager_command_responder_dry_runs_total{type="ssh"} 1
+	`
+	if err := testutil.GatherAndCompare(metrics.Metrics(), strings.NewReader(expected),
+		"alertmanager_command_responder_dry_runs_total"); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	sc := config.NewSafeConfig("../../internal/config/testdata/config-empty.yaml", logger)
+	if code := checkConfig(sc); code != 0 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+	sc = config.NewSafeConfig("../../internal/config/testdata/invalid-ssh_key.yaml", logger)
+	if code := checkConfig(sc); code != 1 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+}
+
+func TestRunCredentialProvider(t *testing.T) {
+	port := "10011"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SSH_PASSWORD", "test")
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+		CredentialProviders: []config.CredentialProvider{
+			{
+				Name: "env",
+				Type: "env",
+				Env:  config.EnvCredentials{Password: "TEST_SSH_PASSWORD"},
+			},
+			{
+				Name:    "ca",
+				Type:    "ca",
+				Timeout: 2 * time.Second,
+				CA: config.CACredentials{
+					PrivateKey: filepath.Join(FixtureDir(), "CA"),
+					Validity:   time.Minute,
+				},
+			},
+		},
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	data := template.Data{
+		Alerts: []template.Alert{
+			template.Alert{
+				Status: "firing",
+				Annotations: template.KV{
+					"cr_ssh_host":                fmt.Sprintf("localhost:%d", sshPort),
+					"cr_ssh_cmd":                 "test10",
+					"cr_ssh_cmd_timeout":         "2s",
+					"cr_ssh_credential_provider": "env",
+				},
+				Fingerprint: "test-credential-provider",
+			},
+		},
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	TestLock.Lock()
+	if !TestResults["test10"] {
+		t.Errorf("Test10 was not executed")
+	}
+	TestResults["test10"] = false
+	TestLock.Unlock()
+
+	// Test ephemeral certificate signed by local CA
+	data.Alerts[0].Annotations["cr_ssh_credential_provider"] = "ca"
+	data.Alerts[0].Annotations["cr_ssh_cmd"] = "test11"
+	jsonData, err = json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	TestLock.Lock()
+	if !TestResults["test11"] {
+		t.Errorf("Test11 was not executed")
+	}
+	TestResults["test11"] = false
+	TestLock.Unlock()
+
+	data.Alerts[0].Annotations["cr_ssh_credential_provider"] = "dne"
+	jsonData, err = json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+}
+
+func TestRunReload(t *testing.T) {
+	port := "10012"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("ssh_user: test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	sc := config.NewSafeConfig(path, logger)
+	if err := sc.ReadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	url := fmt.Sprintf("http://localhost:%s/-/reload", port)
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status code %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	*enableLifecycle = true
+	defer func() { *enableLifecycle = false }()
+	if err := os.WriteFile(path, []byte("ssh_user: test2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if sc.Config().SSHUser != "test2" {
+		t.Errorf("Config was not reloaded, ssh_user=%s", sc.Config().SSHUser)
+	}
+	// Handlers must use the reloaded config rather than the config at startup
+	configResp, err := http.Get(fmt.Sprintf("http://localhost:%s/config", port))
+	if err != nil {
+		t.Fatalf("Unexpected error making GET request: %s", err)
+	}
+	defer configResp.Body.Close()
+	var response struct {
+		Data config.Config `json:"data"`
+	}
+	if err := json.NewDecoder(configResp.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	if response.Data.SSHUser != "test2" {
+		t.Errorf("/config returned old config, ssh_user=%s", response.Data.SSHUser)
+	}
+	if val := testutil.ToFloat64(metrics.ConfigLastReloadSuccessful); val != 1 {
+		t.Errorf("Unexpected config_last_reload_successful %v", val)
+	}
+	if val := testutil.ToFloat64(metrics.ConfigLastReloadSuccessTimestamp); val == 0 {
+		t.Errorf("Unexpected config_last_reload_success_timestamp_seconds %v", val)
+	}
+
+	if err := os.WriteFile(path, []byte("foo: bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+	if sc.Config().SSHUser != "test2" {
+		t.Errorf("Old config was not kept, ssh_user=%s", sc.Config().SSHUser)
+	}
+	if val := testutil.ToFloat64(metrics.ConfigLastReloadSuccessful); val != 0 {
+		t.Errorf("Unexpected config_last_reload_successful %v", val)
+	}
+
+	// A component that fails to load keeps the old config and restores the components already configured
+	dir := t.TempDir()
+	pauseFile := filepath.Join(dir, "pause.json")
+	breakerFile := filepath.Join(dir, "breakers.json")
+	if err := os.WriteFile(breakerFile, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("ssh_user: test3\npause_file: %s\nbreaker_file: %s\n", pauseFile, breakerFile)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+	if sc.Config().SSHUser != "test2" {
+		t.Errorf("Old config was not kept, ssh_user=%s", sc.Config().SSHUser)
+	}
+	if err := pause.Pause("reload", "test"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer pause.Resume("reload")
+	if _, err := os.Stat(pauseFile); !os.IsNotExist(err) {
+		t.Errorf("Expected pause file of the failed config not to be used")
+	}
+}
+
+func TestRunTracing(t *testing.T) {
+	port := "10013"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:             "test",
+		SSHKey:              filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHKnownHosts:       KnownHosts.Name(),
+		SSHCommandTimeout:   2 * time.Second,
+		LocalCommandTimeout: 2 * time.Second,
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	data := template.Data{
+		Alerts: []template.Alert{
+			template.Alert{
+				Status: "firing",
+				Labels: template.KV{"alertname": "tracing"},
+				Annotations: template.KV{
+					"cr_ssh_host":  fmt.Sprintf("localhost:%d", sshPort),
+					"cr_ssh_cmd":   "test12",
+					"cr_local_cmd": "true",
+				},
+				Fingerprint: "test-tracing",
+			},
+		},
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Errorf("Unexpected error generating JSON data: %s", err)
+	}
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	TestLock.Lock()
+	if !TestResults["test12"] {
+		t.Errorf("Test12 was not executed")
+	}
+	TestResults["test12"] = false
+	TestLock.Unlock()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for i := 0; i < 50; i++ {
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+		if _, ok := spans["postAlertHandler"]; ok {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	parents := map[string]string{
+		"postAlertHandler": "",
+		"HandleAlert":      "postAlertHandler",
+		"buildResponse":    "HandleAlert",
+		"exec.Command":     "HandleAlert",
+		"runSSHCommand":    "HandleAlert",
+		"ssh.Dial":         "runSSHCommand",
+		"ssh.Session.Run":  "runSSHCommand",
+	}
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("Span %s not recorded", name)
+			continue
+		}
+		if parent == "" {
+			continue
+		}
+		if p, ok := spans[parent]; ok && span.Parent().SpanID() != p.SpanContext().SpanID() {
+			t.Errorf("Span %s does not have parent %s", name, parent)
+		}
+	}
+	if span, ok := spans["HandleAlert"]; ok {
+		attributes := make(map[string]string)
+		for _, a := range span.Attributes() {
+			attributes[string(a.Key)] = a.Value.Emit()
+		}
+		if attributes["alert.fingerprint"] != "test-tracing" || attributes["responder"] != "tracing" {
+			t.Errorf("Unexpected HandleAlert attributes: %v", attributes)
+		}
+	}
+}
+
+func TestRunAudit(t *testing.T) {
+	port := "10014"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := audit.Configure(path, []byte("audit-key")); err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Configure("", nil)
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:           "test",
+		SSHKey:            filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout: 2 * time.Second,
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	body := `{"version": "4", "groupKey": "{}:{alertname=\"audit\"}", "receiver": "command-responder", "status": "firing",
+		"alerts": [{"status": "firing", "labels": {"alertname": "audit"}, "fingerprint": "test-audit",
+		"annotations": {"cr_ssh_host": "localhost:%d", "cr_ssh_cmd": "test13"}}]}`
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json",
+		strings.NewReader(fmt.Sprintf(body, sshPort)))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	TestLock.Lock()
+	if !TestResults["test13"] {
+		t.Errorf("Test13 was not executed")
+	}
+	TestResults["test13"] = false
+	TestLock.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entry, err := audit.Verify(f, []byte("audit-key"))
+	if err != nil {
+		t.Fatalf("Unexpected error verifying audit log: %s", err)
+	}
+	if entry.Seq != 1 {
+		t.Errorf("Unexpected number of entries: %d", entry.Seq)
+	}
+	if entry.RemoteAddr != "127.0.0.1" && entry.RemoteAddr != "::1" {
+		t.Errorf("Unexpected remote address: %s", entry.RemoteAddr)
+	}
+	if entry.Receiver != "command-responder" || entry.GroupKey != `{}:{alertname="audit"}` {
+		t.Errorf("Unexpected receiver or group key: %s %s", entry.Receiver, entry.GroupKey)
+	}
+	if entry.Command != "test13" || entry.User != "test" || entry.Auth != "key" || entry.Outcome != "success" {
+		t.Errorf("Unexpected entry: %v", entry)
+	}
+	if !strings.HasPrefix(entry.Identity, "SHA256:") {
+		t.Errorf("Unexpected identity: %s", entry.Identity)
+	}
+	if code := verifyAudit(path, []byte("audit-key")); code != 0 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+	if code := verifyAudit(path, nil); code != 1 {
+		t.Errorf("Unexpected exit code %d without audit key", code)
+	}
+	if code := verifyAudit("/dne", nil); code != 1 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+}
+
+func TestRunGroup(t *testing.T) {
+	port := "10015"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:           "test",
+		SSHKey:            filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout: 2 * time.Second,
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	body := `{"version": "4", "groupKey": "{}:{cmd=\"test14\"}", "receiver": "responder", "status": "firing", "truncatedAlerts": 3,
+		"groupLabels": {"cmd": "test14"}, "commonLabels": {"alertname": "group", "cmd": "test14"},
+		"alerts": [
+		{"status": "firing", "labels": {"alertname": "group", "cmd": "test14", "host": "node1"}, "fingerprint": "test-group-1",
+		"annotations": {"cr_mode": "group", "cr_receivers": "responder", "cr_ssh_host": "localhost:%d", "cr_ssh_cmd": "{{ .GroupLabels.cmd }}"}},
+		{"status": "firing", "labels": {"alertname": "group", "cmd": "test14", "host": "node2"}, "fingerprint": "test-group-2",
+		"annotations": {"cr_mode": "group", "cr_receivers": "responder", "cr_ssh_host": "localhost:%d", "cr_ssh_cmd": "{{ .GroupLabels.cmd }}"}}]}`
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/alerts?wait=true", port), "application/json",
+		strings.NewReader(fmt.Sprintf(body, sshPort, sshPort)))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response struct {
+		Data []AlertResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	if len(response.Data) != 1 {
+		t.Fatalf("Unexpected number of results, got %d", len(response.Data))
+	}
+	if strings.Join(response.Data[0].Alerts, ",") != "test-group-1,test-group-2" {
+		t.Errorf("Unexpected group alerts, got %v", response.Data[0].Alerts)
+	}
+	if len(response.Data[0].Results) != 1 || response.Data[0].Results[0].Command != "test14" {
+		t.Errorf("Unexpected results, got %+v", response.Data[0].Results)
+	}
+	TestLock.Lock()
+	if !TestResults["test14"] {
+		t.Errorf("Test14 was not executed")
+	}
+	TestResults["test14"] = false
+	TestLock.Unlock()
+	if val := testutil.ToFloat64(metrics.TruncatedAlertsTotal.WithLabelValues("responder")); val != 3 {
+		t.Errorf("Unexpected truncated alerts, got %v", val)
+	}
+}
+
+func TestRunInputs(t *testing.T) {
+	port := "10016"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:             "test",
+		SSHKey:              filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout:   2 * time.Second,
+		LocalCommandTimeout: 2 * time.Second,
+		Inputs: []config.Input{
+			{Name: "grafana", Type: "grafana"},
+			{Name: "app", Type: "json", JSON: config.JSONInput{
+				Alerts: "$.checks",
+				Labels: map[string]string{"alertname": "$.name", "cmd": "$.command"},
+				StaticAnnotations: map[string]string{
+					"cr_ssh_host": fmt.Sprintf("localhost:%d", sshPort),
+					"cr_ssh_cmd":  "{{ .Labels.cmd }}",
+				},
+			}},
+		},
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%s/inputs/app?wait=true", port), "application/json",
+		strings.NewReader(`{"checks": [{"name": "check", "command": "test15"}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response struct {
+		Data []AlertResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Name != "check" || len(response.Data[0].Results) != 1 {
+		t.Errorf("Unexpected response, got %+v", response.Data)
+	}
+	TestLock.Lock()
+	if !TestResults["test15"] {
+		t.Errorf("Test15 was not executed")
+	}
+	TestResults["test15"] = false
+	TestLock.Unlock()
+
+	body := `{"receiver": "grafana-contact", "status": "firing", "orgId": 1, "state": "alerting",
+		"alerts": [{"status": "firing", "labels": {"alertname": "grafana"}, "annotations": {"cr_local_cmd": "echo grafana"}}]}`
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/inputs/grafana?wait=true", port), "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	if len(response.Data) != 1 || len(response.Data[0].Results) != 1 || response.Data[0].Results[0].Stdout != "grafana\n" {
+		t.Errorf("Unexpected response, got %+v", response.Data)
+	}
+	if response.Data[0].Fingerprint == "" {
+		t.Errorf("Expected fingerprint to be set")
+	}
+
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/inputs/dne", port), "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%s/inputs/app", port), "application/json", strings.NewReader(`{"checks": [`))
+	if err != nil {
+		t.Fatalf("Unexpected error making POST request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestRunPoll(t *testing.T) {
+	port := "10017"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"fingerprint": "test-poll", "labels": {"alertname": "poll"}, "status": {"state": "active"},
+			"receivers": [{"name": "command-responder"}],
+			"annotations": {"cr_ssh_host": "localhost:%d", "cr_ssh_cmd": "test16", "cr_receivers": "command-responder"}}]`, sshPort)
+	}))
+	defer server.Close()
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:           "test",
+		SSHKey:            filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout: 2 * time.Second,
+		Alertmanager: config.AlertmanagerConfig{
+			URL:     server.URL,
+			Timeout: time.Second,
+			Poll: config.PollConfig{
+				Interval:  time.Hour,
+				StateFile: filepath.Join(t.TempDir(), "state.json"),
+			},
+		},
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	executed := false
+	for i := 0; i < 50 && !executed; i++ {
+		time.Sleep(100 * time.Millisecond)
+		TestLock.Lock()
+		executed = TestResults["test16"]
+		TestLock.Unlock()
+	}
+	if !executed {
+		t.Errorf("Test16 was not executed")
+	}
+	TestLock.Lock()
+	TestResults["test16"] = false
+	TestLock.Unlock()
+	if val := testutil.ToFloat64(metrics.PollTrackedAlerts); val != 1 {
+		t.Errorf("Unexpected tracked alerts, got %v", val)
+	}
+}
+
+func TestReloadPoll(t *testing.T) {
+	var lock sync.Mutex
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		queries++
+		lock.Unlock()
+		fmt.Fprint(w, "[]")
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("ssh_user: test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	sc := config.NewSafeConfig(path, logger)
+	if err := reloadConfig(sc, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer startPoller(nil)
+	pollerLock.Lock()
+	running := poller
+	pollerLock.Unlock()
+	if running != nil {
+		t.Errorf("Expected polling to be disabled")
+	}
+
+	// Polling is started when it is enabled by a reload
+	pollConfig := fmt.Sprintf("ssh_user: test\nalertmanager:\n  url: %s\n  poll:\n    interval: 1h\n", server.URL)
+	if err := os.WriteFile(path, []byte(pollConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(sc, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pollerLock.Lock()
+	running = poller
+	pollerLock.Unlock()
+	if running == nil {
+		t.Fatalf("Expected polling to be started")
+	}
+	polled := false
+	for i := 0; i < 50 && !polled; i++ {
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		polled = queries > 0
+		lock.Unlock()
+	}
+	if !polled {
+		t.Errorf("Expected Alertmanager to be polled")
+	}
+
+	// The running poller is kept when the state file does not change
+	if err := os.WriteFile(path, []byte(pollConfig+"    receiver: responder\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(sc, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pollerLock.Lock()
+	kept := poller == running
+	pollerLock.Unlock()
+	if !kept {
+		t.Errorf("Expected the running poller to be kept")
+	}
+
+	// Polling is stopped when it is disabled by a reload
+	if err := os.WriteFile(path, []byte("ssh_user: test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(sc, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pollerLock.Lock()
+	running = poller
+	pollerLock.Unlock()
+	if running != nil {
+		t.Errorf("Expected polling to be stopped")
+	}
+}
+
+func TestReloadConcurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[]")
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("ssh_user: test\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewNopLogger()
+	sc := config.NewSafeConfig(path, logger)
+	if err := sc.ReadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	defer startPoller(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every other reload enables polling with its own state file
+			contents := fmt.Sprintf("ssh_user: test%d\n", i)
+			if i%2 == 0 {
+				contents += fmt.Sprintf("alertmanager:\n  url: %s\n  poll:\n    interval: 1h\n    state_file: %s\n",
+					server.URL, filepath.Join(dir, fmt.Sprintf("poll%d.json", i)))
+			}
+			tmp := filepath.Join(dir, fmt.Sprintf("config%d.yaml", i))
+			if err := os.WriteFile(tmp, []byte(contents), 0600); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := reloadConfig(sc, logger); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// The poller must match the config that won
+	c := sc.Config()
+	pollerLock.Lock()
+	running := poller
+	pollerLock.Unlock()
+	if c.Alertmanager.Poll.Interval > 0 {
+		if running == nil || running.StateFile() != c.Alertmanager.Poll.StateFile {
+			t.Errorf("Poller does not match config with state file %s", c.Alertmanager.Poll.StateFile)
+		}
+	} else if running != nil {
+		t.Errorf("Unexpected poller for config without polling")
+	}
+}
+
+func TestRunMaintenance(t *testing.T) {
+	port := "10018"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:           "test",
+		SSHKey:            filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout: 2 * time.Second,
+		APIToken:          "secret",
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	request := func(method string, path string, body string, token string) (*http.Response, []byte) {
+		return apiRequest(t, port, method, path, body, token)
+	}
+	runResponder := func() []AlertResult {
+		return runTestResponder(t, port, "restart", `{"instance": "web1"}`, "test17")
+	}
+
+	window := `{"id": "patching", "matchers": ["instance=~\"web.*\""], "comment": "Patching"}`
+	if resp, _ := request(http.MethodPost, "/maintenance", window, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp, _ := request(http.MethodPost, "/maintenance", `{"matchers": []}`, "secret"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if resp, body := request(http.MethodPost, "/maintenance", window, "secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d: %s", http.StatusOK, resp.StatusCode, body)
+	}
+	resp, body := request(http.MethodGet, "/maintenance", "", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"id":"patching"`) {
+		t.Errorf("Unexpected maintenance windows %d: %s", resp.StatusCode, body)
+	}
+
+	data := runResponder()
+	if len(data) != 1 || data[0].Skipped != "maintenance" || len(data[0].Results) != 0 {
+		t.Errorf("Unexpected response data: %+v", data)
+	}
+	TestLock.Lock()
+	if TestResults["test17"] {
+		t.Errorf("Test17 was executed during maintenance")
+	}
+	TestLock.Unlock()
+	if val := testutil.ToFloat64(metrics.SuppressedTotal.WithLabelValues("maintenance")); val != 1 {
+		t.Errorf("Unexpected suppressed total, got %v", val)
+	}
+
+	if resp, _ := request(http.MethodDelete, "/maintenance/patching", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp, _ := request(http.MethodDelete, "/maintenance/patching", "", "secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp, _ := request(http.MethodDelete, "/maintenance/patching", "", "secret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	data = runResponder()
+	if len(data) != 1 || data[0].Skipped != "" || len(data[0].Results) != 1 {
+		t.Errorf("Unexpected response data: %+v", data)
+	}
+	TestLock.Lock()
+	if !TestResults["test17"] {
+		t.Errorf("Test17 was not executed")
+	}
+	TestResults["test17"] = false
+	TestLock.Unlock()
+}
+
+func apiRequest(t *testing.T, port string, method string, path string, body string, token string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%s%s", port, path), strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error creating request: %s", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error making %s request: %s", method, err)
+	}
+	defer resp.Body.Close()
+	buffer, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error reading body: %s", err)
+	}
+	return resp, buffer
+}
+
+// runTestResponder runs the responder with the manual run API, the SSH command is one of the test commands.
+func runTestResponder(t *testing.T, port string, name string, labels string, command string) []AlertResult {
+	body := fmt.Sprintf(`{"labels": %s, "annotations": {"cr_ssh_host": "localhost:%d", "cr_ssh_cmd": "%s"}}`, labels, sshPort, command)
+	resp, buffer := apiRequest(t, port, http.MethodPost, fmt.Sprintf("/responders/%s/run", name), body, "secret")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d got %d: %s", http.StatusOK, resp.StatusCode, buffer)
+	}
+	var response struct {
+		Data []AlertResult `json:"data"`
+	}
+	if err := json.Unmarshal(buffer, &response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %s", err)
+	}
+	return response.Data
+}
+
+func TestRunPause(t *testing.T) {
+	port := "10019"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	pauseFile := filepath.Join(t.TempDir(), "pause.json")
+	if err := pause.Configure(pauseFile); err != nil {
+		t.Fatal(err)
+	}
+	defer pause.Configure("")
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:           "test",
+		SSHKey:            filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout: 2 * time.Second,
+		APIToken:          "secret",
+		PauseFile:         pauseFile,
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	executed := func() bool {
+		TestLock.Lock()
+		defer TestLock.Unlock()
+		e := TestResults["test18"]
+		TestResults["test18"] = false
+		return e
+	}
+
+	if resp, _ := apiRequest(t, port, http.MethodPost, "/-/pause/restart", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp, _ := apiRequest(t, port, http.MethodPost, "/-/pause/restart", "", "secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	data := runTestResponder(t, port, "restart", "{}", "test18")
+	if len(data) != 1 || data[0].Skipped != "paused" || len(data[0].Results) != 0 || executed() {
+		t.Errorf("Unexpected response data for paused responder: %+v", data)
+	}
+	data = runTestResponder(t, port, "reboot", "{}", "test18")
+	if len(data) != 1 || data[0].Skipped != "" || !executed() {
+		t.Errorf("Unexpected response data for other responder: %+v", data)
+	}
+
+	if resp, _ := apiRequest(t, port, http.MethodPost, "/-/pause", "", "secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	data = runTestResponder(t, port, "reboot", "{}", "test18")
+	if len(data) != 1 || data[0].Skipped != "paused" || executed() {
+		t.Errorf("Unexpected response data for global pause: %+v", data)
+	}
+	if val := testutil.ToFloat64(metrics.Paused.WithLabelValues("")); val != 1 {
+		t.Errorf("Unexpected paused metric, got %v", val)
+	}
+	resp, body := apiRequest(t, port, http.MethodGet, "/-/pause", "", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"global":{`) || !strings.Contains(string(body), `"restart":{`) {
+		t.Errorf("Unexpected paused state %d: %s", resp.StatusCode, body)
+	}
+	buffer, err := os.ReadFile(pauseFile)
+	if err != nil || !strings.Contains(string(buffer), `"restart"`) {
+		t.Errorf("Unexpected pause file: %s %v", buffer, err)
+	}
+
+	for _, path := range []string{"/-/resume", "/-/resume/restart"} {
+		if resp, _ := apiRequest(t, port, http.MethodPost, path, "", "secret"); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+		}
+	}
+	data = runTestResponder(t, port, "restart", "{}", "test18")
+	if len(data) != 1 || data[0].Skipped != "" || !executed() {
+		t.Errorf("Unexpected response data after resume: %+v", data)
+	}
+	if val := testutil.ToFloat64(metrics.Paused.WithLabelValues("")); val != 0 {
+		t.Errorf("Unexpected paused metric, got %v", val)
+	}
+}
+
+func TestRunBreaker(t *testing.T) {
+	port := "10020"
+	if _, err := kingpin.CommandLine.Parse([]string{fmt.Sprintf("--web.listen-address=:%s", port)}); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser:           "test",
+		SSHKey:            filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHCommandTimeout: 2 * time.Second,
+		APIToken:          "secret",
+	})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	go run(sc, logger)
+	waitForServer(t, port)
+
+	runResponder := func(host string) AlertResult {
+		body := fmt.Sprintf(`{"annotations": {"cr_ssh_host": "%s:%d", "cr_ssh_cmd": "test19",
+			"cr_breaker_max_hosts": "1", "cr_breaker_window": "1h"}}`, host, sshPort)
+		resp, buffer := apiRequest(t, port, http.MethodPost, "/responders/breaker/run", body, "secret")
+		var response struct {
+			Data []AlertResult `json:"data"`
+		}
+		if err := json.Unmarshal(buffer, &response); err != nil || resp.StatusCode != http.StatusOK || len(response.Data) != 1 {
+			t.Fatalf("Unexpected response %d: %s", resp.StatusCode, buffer)
+		}
+		return response.Data[0]
+	}
+	executed := func() bool {
+		TestLock.Lock()
+		defer TestLock.Unlock()
+		e := TestResults["test19"]
+		TestResults["test19"] = false
+		return e
+	}
+
+	// Both hosts are the test SSH server but count as different hosts
+	if result := runResponder("localhost"); result.Skipped != "" || !executed() {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result := runResponder("127.0.0.1"); result.Skipped != "circuit_breaker" || executed() {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result := runResponder("localhost"); result.Skipped != "circuit_breaker" || executed() {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	resp, body := apiRequest(t, port, http.MethodGet, "/-/breakers", "", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"reason":"more than 1 hosts in 1h0m0s"`) {
+		t.Errorf("Unexpected circuit breakers %d: %s", resp.StatusCode, body)
+	}
+	if val := testutil.ToFloat64(metrics.CircuitBreakerTripped.WithLabelValues("breaker")); val != 1 {
+		t.Errorf("Unexpected tripped metric, got %v", val)
+	}
+
+	if resp, _ := apiRequest(t, port, http.MethodPost, "/-/breakers/breaker/reset", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if resp, _ := apiRequest(t, port, http.MethodPost, "/-/breakers/breaker/reset", "", "secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp, _ := apiRequest(t, port, http.MethodPost, "/-/breakers/breaker/reset", "", "secret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	if result := runResponder("127.0.0.1"); result.Skipped != "" || !executed() {
+		t.Errorf("Unexpected result after reset: %+v", result)
+	}
+}
+
+func waitForServer(t *testing.T, port string) {
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%s", port))
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Server on port %s did not start", port)
+}
+
 func resetCounters() {
 	metrics.CommandErrorsTotal.Reset()
 	metrics.CommandErrorsTotal.WithLabelValues("ssh")
@@ -38,6 +38,19 @@ var (
 		"test1.2": false,
 		"test2":   false,
 		"test3":   false,
+		"test7":   false,
+		"test8":   false,
+		"test9":   false,
+		"test10":  false,
+		"test11":  false,
+		"test12":  false,
+		"test13":  false,
+		"test14":  false,
+		"test15":  false,
+		"test16":  false,
+		"test17":  false,
+		"test18":  false,
+		"test19":  false,
 	}
 )
 
@@ -112,8 +125,26 @@ func publicKeyHandler(ctx ssh.Context, key ssh.PublicKey) bool {
 		os.Exit(1)
 	}
 
+	caBuffer, err := os.ReadFile(filepath.Join(FixtureDir(), "CA.pub"))
+	if err != nil {
+		fmt.Printf("ERROR reading public key CA.pub: %s", err)
+		os.Exit(1)
+	}
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey(caBuffer)
+	if err != nil {
+		fmt.Printf("ERROR parsing public key CA.pub: %s", err)
+		os.Exit(1)
+	}
+	certChecker := &gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			return ssh.KeysEqual(auth, caKey)
+		},
+	}
+
 	if ssh.KeysEqual(key, pubKey) {
 		return true
+	} else if cert, ok := key.(*gossh.Certificate); ok && certChecker.IsUserAuthority(cert.SignatureKey) {
+		return certChecker.CheckCert(ctx.User(), cert) == nil
 	} else if ssh.KeysEqual(key, pubCert) {
 		return true
 	} else {
@@ -0,0 +1,224 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/alert"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	simulateCmd       = kingpin.Command("simulate", "Show how alerts would be handled without Alertmanager")
+	simulatePayload   = simulateCmd.Flag("payload", "Path to Alertmanager webhook JSON payload, - for stdin").String()
+	simulateRules     = simulateCmd.Flag("rules", "Path to Prometheus rules file to build alerts from").String()
+	simulateAlertName = simulateCmd.Flag("alertname", "Only build alerts from rules with this alert name").String()
+	simulateLabels    = simulateCmd.Flag("label", "Label to add to alerts built from rules, eg: instance=host:9100").StringMap()
+	simulateValue     = simulateCmd.Flag("value", "Value of alerts built from rules").Default("0").Float64()
+	simulateStatus    = simulateCmd.Flag("status", "Status of alerts built from rules").Default("firing").Enum("firing", "resolved")
+	simulateExecute   = simulateCmd.Flag("execute", "Execute the commands instead of a dry run").Default("false").Bool()
+	simulateSSHHost   = simulateCmd.Flag("ssh-host", "Override the SSH host of every alert, eg: a local test target").String()
+)
+
+type ruleGroups struct {
+	Groups []struct {
+		Name  string `yaml:"name"`
+		Rules []struct {
+			Alert       string            `yaml:"alert"`
+			Labels      map[string]string `yaml:"labels"`
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"rules"`
+	} `yaml:"groups"`
+}
+
+func loadPayload(path string) ([]template.Alert, error) {
+	var reader io.Reader
+	if path == "-" {
+		reader = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+	var data template.Data
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		return nil, fmt.Errorf("Unable to parse payload %s: %v", path, err)
+	}
+	return data.Alerts, nil
+}
+
+// loadRules builds alerts from the alerting rules in a Prometheus rules file.
+// Annotations are expanded with the same $labels and $value variables as Prometheus.
+func loadRules(path string, alertname string, labels map[string]string, value float64, status string) ([]template.Alert, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var groups ruleGroups
+	if err := yaml.Unmarshal(buffer, &groups); err != nil {
+		return nil, fmt.Errorf("Unable to parse rules %s: %v", path, err)
+	}
+	var alerts []template.Alert
+	for _, group := range groups.Groups {
+		for _, rule := range group.Rules {
+			if rule.Alert == "" || (alertname != "" && rule.Alert != alertname) {
+				continue
+			}
+			ruleLabels := template.KV{}
+			for k, v := range labels {
+				ruleLabels[k] = v
+			}
+			for k, v := range rule.Labels {
+				ruleLabels[k] = v
+			}
+			a := alert.NewManualAlert(rule.Alert, status, ruleLabels, nil)
+			for k, v := range rule.Annotations {
+				expanded, err := expandRuleTemplate(k, v, a.Labels, value)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to expand annotation %s of rule %s: %v", k, rule.Alert, err)
+				}
+				a.Annotations[k] = expanded
+			}
+			if status == "resolved" {
+				a.EndsAt = time.Now()
+			}
+			alerts = append(alerts, a.Alert)
+		}
+	}
+	if len(alerts) == 0 {
+		return nil, fmt.Errorf("No alerting rules found in %s", path)
+	}
+	return alerts, nil
+}
+
+func expandRuleTemplate(name string, text string, labels template.KV, value float64) (string, error) {
+	defs := "{{$labels := .Labels}}{{$value := .Value}}"
+	tmpl, err := texttemplate.New(name).Option("missingkey=zero").Parse(defs + text)
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	data := struct {
+		Labels template.KV
+		Value  float64
+	}{labels, value}
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func simulate(c *config.Config, alerts []template.Alert, execute bool, sshHost string, out io.Writer, logger log.Logger) int {
+	code := 0
+	newAlerts := make([]alert.Alert, len(alerts))
+	for i, a := range alerts {
+		if sshHost != "" {
+			annotations := template.KV{}
+			for k, v := range a.Annotations {
+				annotations[k] = v
+			}
+			annotations["cr_ssh_host"] = sshHost
+			a.Annotations = annotations
+		}
+		newAlerts[i] = alert.Alert{
+			Alert:    a,
+			DryRun:   !execute,
+			Simulate: true,
+		}
+	}
+	for _, newAlert := range alert.GroupAlerts(newAlerts) {
+		err := newAlert.HandleAlert(context.Background(), c, logger)
+		fmt.Fprintf(out, "Alert %s fingerprint=%s status=%s\n", newAlert.Name(), newAlert.Fingerprint, newAlert.Status)
+		fmt.Fprintf(out, "  responder: %s\n", newAlert.Name())
+		if len(newAlert.Alerts) > 0 {
+			fingerprints := make([]string, len(newAlert.Alerts))
+			for i, a := range newAlert.Alerts {
+				fingerprints[i] = a.Fingerprint
+			}
+			fmt.Fprintf(out, "  group: %s\n", strings.Join(fingerprints, ","))
+		}
+		if len(newAlert.Response.Status) > 0 {
+			decision := "match"
+			if newAlert.Skipped == "status" {
+				decision = "skip"
+			}
+			fmt.Fprintf(out, "  status filter: %s (%s)\n", strings.Join(newAlert.Response.Status, ","), decision)
+		}
+		if newAlert.Skipped != "" && newAlert.Skipped != "status" {
+			fmt.Fprintf(out, "  skipped: %s\n", newAlert.Skipped)
+		}
+		if newAlert.Level > 0 {
+			fmt.Fprintf(out, "  escalation level: %d\n", newAlert.Level)
+		}
+		for _, r := range newAlert.Results {
+			fmt.Fprintf(out, "  %s command: %s\n", r.Type, r.Command)
+			if r.Type == "ssh" {
+				fmt.Fprintf(out, "    host: %s user: %s auth: %s\n", r.Host, newAlert.Response.SSHUser, r.Auth)
+			}
+			if r.DryRun {
+				fmt.Fprintf(out, "    result: dry run\n")
+				continue
+			}
+			fmt.Fprintf(out, "    result: duration=%.3fs timed_out=%v\n", r.Duration, r.TimedOut)
+			if r.Stdout != "" {
+				fmt.Fprintf(out, "    stdout: %s\n", strings.TrimSpace(r.Stdout))
+			}
+			if r.Stderr != "" {
+				fmt.Fprintf(out, "    stderr: %s\n", strings.TrimSpace(r.Stderr))
+			}
+		}
+		if newAlert.Skipped == "" && len(newAlert.Results) == 0 && err == nil {
+			fmt.Fprintf(out, "  no commands\n")
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  error: %s\n", err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func runSimulate(sc *config.SafeConfig, logger log.Logger) int {
+	var alerts []template.Alert
+	var err error
+	switch {
+	case *simulatePayload != "":
+		alerts, err = loadPayload(*simulatePayload)
+	case *simulateRules != "":
+		alerts, err = loadRules(*simulateRules, *simulateAlertName, *simulateLabels, *simulateValue, *simulateStatus)
+	default:
+		err = fmt.Errorf("One of --payload or --rules is required")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return simulate(sc.Config(), alerts, *simulateExecute, *simulateSSHHost, os.Stdout, logger)
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func TestSimulatePayload(t *testing.T) {
+	alerts, err := loadPayload(filepath.Join(FixtureDir(), "simulate-payload.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	c := &config.Config{
+		SSHUser: "test",
+		SSHKey:  filepath.Join(FixtureDir(), "id_rsa_test1"),
+	}
+	var out bytes.Buffer
+	code := simulate(c, alerts, false, "", &out, log.NewNopLogger())
+	if code != 0 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+	expected := `Alert HttpdDown fingerprint=a1 status=firing
+  responder: HttpdDown
+  status filter: firing (match)
+  ssh command: systemctl restart httpd
+    host: web01:22 user: test auth: key
+    result: dry run
+Alert DiskFull fingerprint=a2 status=resolved
+  responder: DiskFull
+  status filter: firing (skip)
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected output\nExpected:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestSimulateGroup(t *testing.T) {
+	annotations := template.KV{
+		"cr_mode":      "group",
+		"cr_local_cmd": "drain{{ range .Alerts.Firing }} {{ .Labels.host }}{{ end }}",
+	}
+	alerts := []template.Alert{
+		{Status: "firing", Labels: template.KV{"alertname": "NodeDown", "host": "node1"}, Annotations: annotations, Fingerprint: "g1"},
+		{Status: "firing", Labels: template.KV{"alertname": "NodeDown", "host": "node2"}, Annotations: annotations, Fingerprint: "g2"},
+	}
+	var out bytes.Buffer
+	code := simulate(&config.Config{}, alerts, false, "", &out, log.NewNopLogger())
+	if code != 0 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+	if strings.Count(out.String(), "Alert NodeDown") != 1 {
+		t.Errorf("Expected one alert, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "  group: g1,g2\n") || !strings.Contains(out.String(), "local command: drain node1 node2\n") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestSimulateSkipped(t *testing.T) {
+	alerts := []template.Alert{{
+		Status:      "firing",
+		Labels:      template.KV{"alertname": "HttpdDown"},
+		Annotations: template.KV{"cr_local_cmd": "systemctl restart httpd", "cr_min_firing_duration": "1h"},
+		StartsAt:    time.Now(),
+		Fingerprint: "s1",
+	}}
+	var out bytes.Buffer
+	code := simulate(&config.Config{}, alerts, false, "", &out, log.NewNopLogger())
+	if code != 0 {
+		t.Errorf("Unexpected exit code %d", code)
+	}
+	expected := `Alert HttpdDown fingerprint=s1 status=firing
+  responder: HttpdDown
+  status filter: firing (match)
+  skipped: min_firing_duration
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected output\nExpected:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestSimulateRules(t *testing.T) {
+	alerts, err := loadRules(filepath.Join(FixtureDir(), "rules.yaml"), "", map[string]string{"instance": "web01:22"}, 5, "firing")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("Unexpected number of alerts, got %d", len(alerts))
+	}
+	if val := alerts[0].Annotations["cr_ssh_host"]; val != "web01:22" {
+		t.Errorf("Unexpected cr_ssh_host, got %s", val)
+	}
+	if val := alerts[0].Labels["severity"]; val != "critical" {
+		t.Errorf("Unexpected severity, got %s", val)
+	}
+	if val := alerts[1].Annotations["cr_local_cmd"]; val != "echo 5" {
+		t.Errorf("Unexpected cr_local_cmd, got %s", val)
+	}
+	if _, err = loadRules(filepath.Join(FixtureDir(), "rules.yaml"), "dne", nil, 0, "firing"); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	alerts, err = loadRules(filepath.Join(FixtureDir(), "rules.yaml"), "HttpdDown", nil, 0, "firing")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	c := &config.Config{
+		SSHUser:              "test",
+		SSHKey:               filepath.Join(FixtureDir(), "id_rsa_test1"),
+		SSHConnectionTimeout: 2 * time.Second,
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	var out bytes.Buffer
+	code := simulate(c, alerts, true, fmt.Sprintf("localhost:%d", sshPort), &out, logger)
+	if code != 0 {
+		t.Errorf("Unexpected exit code %d, output:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "result: duration=") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	TestLock.Lock()
+	if !TestResults["test9"] {
+		t.Errorf("Test9 was not executed")
+	}
+	TestResults["test9"] = false
+	TestLock.Unlock()
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/treydock/alertmanager-command-responder/internal/audit"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+var (
+	verifyAuditCmd  = kingpin.Command("verify-audit", "Verify the hash chain and checkpoint of the audit log and exit")
+	verifyAuditFile = verifyAuditCmd.Arg("file", "Audit log to verify, default is audit_log from the configuration file").String()
+)
+
+func verifyAudit(path string, key []byte) int {
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open audit log %s: %s\n", path, err)
+		return 1
+	}
+	last, err := audit.VerifyFile(path, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Audit log %s is invalid after %d valid entries: %s\n", path, last.Seq, err)
+		return 1
+	}
+	fmt.Printf("Audit log %s is valid, %d entries\n", path, last.Seq)
+	return 0
+}
+
+func runVerifyAudit(sc *config.SafeConfig) int {
+	if err := sc.ReadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read configuration file: %s\n", err)
+		return 1
+	}
+	path := *verifyAuditFile
+	if path == "" {
+		path = sc.Config().AuditLog
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "No audit log given and audit_log is not set in the configuration file")
+		return 1
+	}
+	return verifyAudit(path, []byte(sc.Config().AuditKey))
+}
@@ -14,7 +14,11 @@
 package alert
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,9 +26,17 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/treydock/alertmanager-command-responder/internal/audit"
+	"github.com/treydock/alertmanager-command-responder/internal/breaker"
 	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/events"
 	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/notify"
+	"github.com/treydock/alertmanager-command-responder/internal/tracing"
 	"github.com/treydock/alertmanager-command-responder/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -38,28 +50,149 @@ const (
 	sshCommandTimeout      = "cr_ssh_cmd_timeout"
 	localCommandAnnotation = "cr_local_cmd"
 	localCommandTimeout    = "cr_local_cmd_timeout"
+	dryRunAnnotation       = "cr_dry_run"
+	sshCredentialProvider  = "cr_ssh_credential_provider"
+	notifiersAnnotation    = "cr_notifiers"
+	notifyOnAnnotation     = "cr_notify_on"
+	silenceDuration        = "cr_silence_duration"
+	silenceOnAnnotation    = "cr_silence_on"
+	silenceMatchers        = "cr_silence_matchers"
+	silenceExpireOnResolve = "cr_silence_expire_on_resolve"
+	grafanaAnnotation      = "cr_grafana_annotate"
+	receiversAnnotation    = "cr_receivers"
+	modeAnnotation         = "cr_mode"
+	cleanupSSHCommand      = "cr_cleanup_ssh_cmd"
+	cleanupLocalCommand    = "cr_cleanup_local_cmd"
+	minFiringDuration      = "cr_min_firing_duration"
+	escalationAfter        = "cr_escalation_%d_after"
+	escalationSSHCommand   = "cr_escalation_%d_ssh_cmd"
+	escalationLocalCommand = "cr_escalation_%d_local_cmd"
+	scheduleAnnotation     = "cr_schedule"
+	breakerWindow          = "cr_breaker_window"
+	breakerMaxHosts        = "cr_breaker_max_hosts"
+	breakerMaxAlerting     = "cr_breaker_max_alerting_percent"
+	breakerTargets         = "cr_breaker_targets"
 )
 
 type Alert struct {
 	template.Alert
-	logger   log.Logger
-	Response AlertResponse `json:"response"`
+	Source    Source `json:"source"`
+	logger    log.Logger
+	Response  AlertResponse   `json:"response"`
+	Results   []CommandResult `json:"results"`
+	Skipped   string          `json:"skipped,omitempty"`
+	DryRun    bool            `json:"dry_run"`
+	SilenceID string          `json:"silence_id,omitempty"`
+	// Simulate runs the commands without notifying, silencing, annotating or saving state
+	Simulate bool `json:"-"`
+	// Alerts are the alerts combined into this alert when the responder runs once per group
+	Alerts template.Alerts `json:"alerts,omitempty"`
+	// Level is the escalation level of the commands, 0 for the commands of the responder
+	Level int `json:"level,omitempty"`
+}
+
+// Source describes where an alert was received from.
+// The group fields are copied from the Alertmanager notification the alert was part of.
+type Source struct {
+	RemoteAddr        string      `json:"remote_addr,omitempty"`
+	Receiver          string      `json:"receiver,omitempty"`
+	GroupKey          string      `json:"group_key,omitempty"`
+	GroupLabels       template.KV `json:"group_labels,omitempty"`
+	CommonLabels      template.KV `json:"common_labels,omitempty"`
+	CommonAnnotations template.KV `json:"common_annotations,omitempty"`
+	ExternalURL       string      `json:"external_url,omitempty"`
+	TruncatedAlerts   uint64      `json:"truncated_alerts,omitempty"`
 }
 
 type AlertResponse struct {
-	Status               []string      `json:"status"`
-	SSHUser              string        `json:"ssh_user"`
-	SSHKey               string        `json:"ssh_key"`
-	SSHCertificate       string        `json:"ssh_certificate"`
-	SSHPassword          string        `json:"ssh_password"`
-	SSHKnownHosts        string        `json:"ssh_known_hosts"`
-	SSHHostKeyAlgorithms []string      `json:"ssh_host_key_algorithms"`
-	SSHConnectionTimeout time.Duration `json:"ssh_connection_timeout"`
-	SSHCommandTimeout    time.Duration `json:"ssh_command_timeout"`
-	SSHHost              string        `json:"ssh_host"`
-	SSHCommand           string        `json:"ssh_command"`
-	LocalCommand         string        `json:"local_command"`
-	LocalCommandTimeout  time.Duration `json:"local_command_timeout"`
+	Status                []string       `json:"status"`
+	SSHUser               string         `json:"ssh_user"`
+	SSHKey                string         `json:"ssh_key"`
+	SSHCertificate        string         `json:"ssh_certificate"`
+	SSHPassword           config.Secret  `json:"ssh_password"`
+	SSHKnownHosts         string         `json:"ssh_known_hosts"`
+	SSHHostKeyAlgorithms  []string       `json:"ssh_host_key_algorithms"`
+	SSHConnectionTimeout  time.Duration  `json:"ssh_connection_timeout"`
+	SSHCommandTimeout     time.Duration  `json:"ssh_command_timeout"`
+	SSHHost               string         `json:"ssh_host"`
+	SSHCommand            string         `json:"ssh_command"`
+	LocalCommand          string         `json:"local_command"`
+	LocalCommandTimeout   time.Duration  `json:"local_command_timeout"`
+	DryRun                bool           `json:"dry_run"`
+	SSHCredentialProvider string         `json:"ssh_credential_provider"`
+	Notifiers             []string       `json:"notifiers"`
+	NotifyOn              []string       `json:"notify_on"`
+	SilenceDuration       time.Duration  `json:"silence_duration"`
+	SilenceOn             []string       `json:"silence_on"`
+	SilenceMatchers       []string       `json:"silence_matchers"`
+	SilenceExpire         bool           `json:"silence_expire_on_resolve"`
+	GrafanaAnnotate       bool           `json:"grafana_annotate"`
+	Receivers             []string       `json:"receivers"`
+	Mode                  string         `json:"mode"`
+	CleanupSSHCommand     string         `json:"cleanup_ssh_command"`
+	CleanupLocalCommand   string         `json:"cleanup_local_command"`
+	MinFiringDuration     time.Duration  `json:"min_firing_duration"`
+	Escalations           []Escalation   `json:"escalations"`
+	Schedule              string         `json:"schedule"`
+	Breaker               breaker.Limits `json:"breaker"`
+	credentialProvider    config.CredentialProvider
+	schedule              config.Schedule
+	notifiers             []config.Notifier
+}
+
+// Escalation is a level of commands run once the alert has been firing for longer than After.
+type Escalation struct {
+	After        time.Duration `json:"after"`
+	SSHCommand   string        `json:"ssh_command"`
+	LocalCommand string        `json:"local_command"`
+}
+
+type CommandResult struct {
+	Type     string  `json:"type"`
+	Command  string  `json:"command"`
+	Host     string  `json:"host,omitempty"`
+	Auth     string  `json:"auth,omitempty"`
+	Identity string  `json:"identity,omitempty"`
+	Stdout   string  `json:"stdout"`
+	Stderr   string  `json:"stderr"`
+	Error    string  `json:"error,omitempty"`
+	TimedOut bool    `json:"timed_out"`
+	DryRun   bool    `json:"dry_run,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+// NewManualAlert builds a synthetic alert for running a responder on demand.
+// The responder name is used as the alertname label.
+func NewManualAlert(name string, status string, labels template.KV, annotations template.KV) Alert {
+	if status == "" {
+		status = "firing"
+	}
+	lbls := template.KV{}
+	for k, v := range labels {
+		lbls[k] = v
+	}
+	lbls["alertname"] = name
+	if annotations == nil {
+		annotations = template.KV{}
+	}
+	return Alert{
+		Alert: template.Alert{
+			Status:      status,
+			Labels:      lbls,
+			Annotations: annotations,
+			StartsAt:    time.Now(),
+			Fingerprint: fingerprint(lbls),
+		},
+	}
+}
+
+// fingerprint returns the Alertmanager fingerprint of the labels.
+func fingerprint(labels template.KV) string {
+	labelSet := model.LabelSet{}
+	for k, v := range labels {
+		labelSet[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return labelSet.Fingerprint().String()
 }
 
 func (a *Alert) Name() string {
@@ -69,31 +202,126 @@ func (a *Alert) Name() string {
 	return a.Alert.Fingerprint
 }
 
-func (a *Alert) HandleAlert(c *config.Config, logger log.Logger) error {
+func (a *Alert) HandleAlert(ctx context.Context, c *config.Config, logger log.Logger) error {
 	var err error
+	ctx, span := tracing.Tracer().Start(ctx, "HandleAlert", trace.WithAttributes(
+		attribute.String("alert.fingerprint", a.Alert.Fingerprint),
+		attribute.String("alert.status", a.Alert.Status),
+		attribute.String("responder", a.Name()),
+	))
+	defer span.End()
 	a.logger = log.With(logger, "alert", a.Alert.Fingerprint, "alertname", a.Name())
 	level.Debug(a.logger).Log("msg", "Handling alert")
+	_, responseSpan := tracing.Tracer().Start(ctx, "buildResponse")
 	r, err := a.buildResponse(c)
 	if err != nil {
 		level.Error(a.logger).Log("msg", "Error building alert response", "err", err)
 		metrics.ErrorsTotal.Inc()
+		spanError(responseSpan, err)
+		responseSpan.End()
+		spanError(span, err)
 		return err
 	}
-	if !utils.SliceContains(r.Status, a.Alert.Status) {
+	responseSpan.End()
+	a.Response = r
+	sideEffects := !a.DryRun && !r.DryRun && !a.Simulate
+	if a.Alert.Status == "resolved" && sideEffects {
+		defer a.endEpisode()
+	}
+	if r.Breaker.Enabled() && sideEffects {
+		a.trackAlerting()
+	}
+	if a.Alert.Status == "resolved" && r.SilenceExpire && sideEffects {
+		a.expireSilences(c)
+	}
+	if a.Alert.Status == "resolved" && (r.CleanupSSHCommand != "" || r.CleanupLocalCommand != "") {
+		if reason := a.cleanupSkipReason(); reason != "" {
+			level.Info(a.logger).Log("msg", "Skipping cleanup commands", "reason", reason)
+			a.Skipped = reason
+			span.SetAttributes(attribute.String("skipped", a.Skipped))
+			return nil
+		}
+		a.Response.SSHCommand = r.CleanupSSHCommand
+		a.Response.LocalCommand = r.CleanupLocalCommand
+	} else if !utils.SliceContains(r.Status, a.Alert.Status) {
 		level.Debug(a.logger).Log("msg", "Alert status does not match alert", "status", a.Alert.Status, "expected", strings.Join(r.Status, ","))
+		a.Skipped = "status"
+		span.SetAttributes(attribute.String("skipped", a.Skipped))
 		return nil
+	} else if a.Alert.Status == "firing" {
+		if reason := a.escalate(); reason != "" {
+			level.Info(a.logger).Log("msg", "Skipping commands", "reason", reason, "firing", time.Since(a.Alert.StartsAt).Round(time.Second))
+			a.Skipped = reason
+			span.SetAttributes(attribute.String("skipped", a.Skipped))
+			return nil
+		}
+		span.SetAttributes(attribute.Int("level", a.Level))
 	}
-	a.Response = r
+	if a.Source.Receiver != "" && len(r.Receivers) > 0 && !utils.SliceContains(r.Receivers, a.Source.Receiver) {
+		level.Debug(a.logger).Log("msg", "Receiver does not match alert", "receiver", a.Source.Receiver, "expected", strings.Join(r.Receivers, ","))
+		a.Skipped = "receiver"
+		span.SetAttributes(attribute.String("skipped", a.Skipped))
+		return nil
+	}
+	a.DryRun = a.DryRun || r.DryRun
+	span.SetAttributes(attribute.Bool("dry_run", a.DryRun))
+	if reason := a.suppressReason(time.Now()); reason != "" {
+		level.Info(a.logger).Log("msg", "Suppressing commands", "reason", reason)
+		a.Skipped = reason
+		span.SetAttributes(attribute.String("skipped", a.Skipped))
+		if sideEffects {
+			a.suppress(reason)
+		}
+		return nil
+	}
+	if r.Breaker.Enabled() && (a.Response.SSHCommand != "" || a.Response.LocalCommand != "") && a.breakerOpen() {
+		a.Skipped = "circuit_breaker"
+		span.SetAttributes(attribute.String("skipped", a.Skipped))
+		if sideEffects {
+			a.suppress(a.Skipped)
+		}
+		return nil
+	}
+	err = a.runCommands(ctx)
+	if err != nil {
+		spanError(span, err)
+	}
+	if sideEffects && (len(a.Results) > 0 || err != nil) {
+		outcome := a.outcome(err)
+		if a.Alert.Status == "firing" {
+			a.recordExecution(outcome)
+		}
+		a.notify(outcome)
+		a.silence(c, outcome)
+		if r.GrafanaAnnotate {
+			a.annotate(c)
+		}
+	}
+	return err
+}
 
+func (a *Alert) runCommands(ctx context.Context) error {
+	var err error
+	r := a.Response
 	start := time.Now()
 	if a.Response.LocalCommand != "" {
 		localLogger := log.With(a.logger, "type", "local", "command", r.LocalCommand)
-		err = a.Response.runLocalCommand(localLogger)
-		if err != nil {
-			level.Error(localLogger).Log("msg", "Failed to run local command", "err", err)
-			metrics.CommandErrorsTotal.With(prometheus.Labels{"type": "local"}).Inc()
+		var result CommandResult
+		if a.DryRun {
+			level.Info(localLogger).Log("msg", "Dry run, not running command")
+			metrics.DryRunsTotal.With(prometheus.Labels{"type": "local"}).Inc()
+			result = CommandResult{Type: "local", Command: r.LocalCommand, DryRun: true}
+		} else {
+			result, err = a.Response.runLocalCommand(ctx, localLogger)
+			if err != nil {
+				level.Error(localLogger).Log("msg", "Failed to run local command", "err", err)
+				metrics.CommandErrorsTotal.With(prometheus.Labels{"type": "local"}).Inc()
+			}
+			result.Duration = time.Since(start).Seconds()
+			level.Info(localLogger).Log("msg", "Command completed", "duration", result.Duration)
+			a.emit(result, "", localLogger)
 		}
-		level.Info(localLogger).Log("msg", "Command completed", "duration", time.Since(start).Seconds())
+		a.Results = append(a.Results, result)
 	}
 	if a.Response.SSHCommand != "" {
 		if a.Response.SSHHost == "" {
@@ -103,28 +331,160 @@ func (a *Alert) HandleAlert(c *config.Config, logger log.Logger) error {
 			return err
 		}
 		sshLogger := log.With(a.logger, "type", "ssh", "ssh_user", r.SSHUser, "ssh_key", r.SSHKey,
-			"ssh_cert", r.SSHCertificate, "ssh_host", r.SSHHost, "command", r.SSHCommand)
-		err = a.Response.runSSHCommand(sshLogger)
-		if err != nil {
-			level.Error(sshLogger).Log("msg", "Failed to run SSH command", "err", err)
-			metrics.CommandErrorsTotal.With(prometheus.Labels{"type": "ssh"}).Inc()
+			"ssh_cert", r.SSHCertificate, "ssh_credential_provider", r.SSHCredentialProvider,
+			"ssh_host", r.SSHHost, "command", r.SSHCommand)
+		var result CommandResult
+		if a.DryRun {
+			level.Info(sshLogger).Log("msg", "Dry run, not running command", "auth", r.authMethod())
+			metrics.DryRunsTotal.With(prometheus.Labels{"type": "ssh"}).Inc()
+			result = CommandResult{Type: "ssh", Command: r.SSHCommand, Host: r.SSHHost, Auth: r.authMethod(), DryRun: true}
+		} else {
+			result, err = a.Response.runSSHCommand(ctx, sshLogger)
+			if err != nil {
+				level.Error(sshLogger).Log("msg", "Failed to run SSH command", "err", err)
+				metrics.CommandErrorsTotal.With(prometheus.Labels{"type": "ssh"}).Inc()
+			}
+			result.Duration = time.Since(start).Seconds()
+			level.Info(sshLogger).Log("msg", "Command completed", "duration", result.Duration)
+			a.emit(result, "", sshLogger)
 		}
-		level.Info(sshLogger).Log("msg", "Command completed", "duration", time.Since(start).Seconds())
+		a.Results = append(a.Results, result)
 	}
 	return err
 }
 
+// emit records the command execution in the audit log and sends an event to the configured event sinks.
+// A command that did not run because it was suppressed has the reason it was suppressed.
+func (a *Alert) emit(result CommandResult, reason string, logger log.Logger) {
+	now := time.Now()
+	outcome := result.outcome()
+	if reason != "" {
+		outcome = "suppressed"
+	}
+	user := a.Response.SSHUser
+	if result.Type == "local" {
+		user = localUser()
+	}
+	err := audit.Record(audit.Entry{
+		Time:        now,
+		RemoteAddr:  a.Source.RemoteAddr,
+		Receiver:    a.Source.Receiver,
+		GroupKey:    a.Source.GroupKey,
+		Responder:   a.Name(),
+		Fingerprint: a.Alert.Fingerprint,
+		Status:      a.Alert.Status,
+		Labels:      a.Alert.Labels,
+		Type:        result.Type,
+		Command:     result.Command,
+		Host:        result.Host,
+		User:        user,
+		Auth:        result.Auth,
+		Identity:    result.Identity,
+		Outcome:     outcome,
+		Error:       result.Error,
+		Reason:      reason,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "Unable to write audit log", "err", err)
+		metrics.ErrorsTotal.Inc()
+	}
+	events.Emit(events.Event{
+		Time:        now,
+		Responder:   a.Name(),
+		Fingerprint: a.Alert.Fingerprint,
+		Status:      a.Alert.Status,
+		Labels:      a.Alert.Labels,
+		Type:        result.Type,
+		Command:     result.Command,
+		Host:        result.Host,
+		Auth:        result.Auth,
+		Outcome:     outcome,
+		Error:       result.Error,
+		Reason:      reason,
+		TimedOut:    result.TimedOut,
+		Duration:    result.Duration,
+	}, logger)
+}
+
+func localUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// outcome returns success, failure or timeout for the command.
+func (r CommandResult) outcome() string {
+	if r.TimedOut {
+		return "timeout"
+	} else if r.Error != "" {
+		return "failure"
+	}
+	return "success"
+}
+
+// outcome summarizes the command results as success, failure or timeout.
+func (a *Alert) outcome(err error) string {
+	outcome := "success"
+	for _, result := range a.Results {
+		if result.TimedOut {
+			return "timeout"
+		}
+		if result.Error != "" {
+			outcome = "failure"
+		}
+	}
+	if err != nil {
+		outcome = "failure"
+	}
+	return outcome
+}
+
+// notify sends the outcome of the commands to the notifiers configured for the alert.
+func (a *Alert) notify(outcome string) {
+	if !utils.SliceContains(a.Response.NotifyOn, outcome) {
+		return
+	}
+	msg := notify.Message{
+		Responder:   a.Name(),
+		Fingerprint: a.Alert.Fingerprint,
+		Status:      a.Alert.Status,
+		Outcome:     outcome,
+		Labels:      a.Alert.Labels,
+		Annotations: a.Alert.Annotations,
+		Results:     a.Results,
+	}
+	a.send(msg)
+}
+
+// send sends the message to the notifiers configured for the alert.
+func (a *Alert) send(msg notify.Message) {
+	for _, n := range a.Response.notifiers {
+		if err := notify.Send(n, msg); err != nil {
+			level.Error(a.logger).Log("msg", "Error sending notification", "notifier", n.Name, "err", err)
+			metrics.NotificationErrorsTotal.With(prometheus.Labels{"notifier": n.Name}).Inc()
+			continue
+		}
+		level.Debug(a.logger).Log("msg", "Sent notification", "notifier", n.Name, "outcome", msg.Outcome)
+	}
+}
+
 func (a *Alert) buildResponse(c *config.Config) (AlertResponse, error) {
 	r := AlertResponse{
-		SSHUser:              c.SSHUser,
-		SSHKey:               c.SSHKey,
-		SSHPassword:          c.SSHPassword,
-		SSHCertificate:       c.SSHCertificate,
-		SSHKnownHosts:        c.SSHKnownHosts,
-		SSHHostKeyAlgorithms: c.SSHHostKeyAlgorithms,
-		SSHConnectionTimeout: c.SSHConnectionTimeout,
-		SSHCommandTimeout:    c.SSHCommandTimeout,
-		LocalCommandTimeout:  c.LocalCommandTimeout,
+		SSHUser:               c.SSHUser,
+		SSHKey:                c.SSHKey,
+		SSHPassword:           c.SSHPassword,
+		SSHCertificate:        c.SSHCertificate,
+		SSHKnownHosts:         c.SSHKnownHosts,
+		SSHHostKeyAlgorithms:  c.SSHHostKeyAlgorithms,
+		SSHConnectionTimeout:  c.SSHConnectionTimeout,
+		SSHCommandTimeout:     c.SSHCommandTimeout,
+		LocalCommandTimeout:   c.LocalCommandTimeout,
+		SSHCredentialProvider: c.SSHCredentialProvider,
+		Notifiers:             c.DefaultNotifiers,
+		NotifyOn:              c.NotifyOn,
+		GrafanaAnnotate:       c.Grafana.URL != "",
 	}
 	if val, ok := a.Alert.Annotations[statusAnnotation]; ok {
 		r.Status = strings.Split(val, ",")
@@ -132,7 +492,12 @@ func (a *Alert) buildResponse(c *config.Config) (AlertResponse, error) {
 		r.Status = []string{"firing"}
 	}
 	if val, ok := a.Alert.Annotations[sshUserAnnotation]; ok {
-		r.SSHUser = val
+		rendered, err := a.render(sshUserAnnotation, val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to render SSH user", "err", err, "template", val)
+			return r, err
+		}
+		r.SSHUser = rendered
 	}
 	if val, ok := a.Alert.Annotations[sshKeyAnnotation]; ok {
 		r.SSHKey = val
@@ -141,10 +506,32 @@ func (a *Alert) buildResponse(c *config.Config) (AlertResponse, error) {
 		r.SSHCertificate = val
 	}
 	if val, ok := a.Alert.Annotations[sshHostAnnotation]; ok {
-		r.SSHHost = val
+		rendered, err := a.render(sshHostAnnotation, val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to render SSH host", "err", err, "template", val)
+			return r, err
+		}
+		r.SSHHost = rendered
 	}
 	if val, ok := a.Alert.Annotations[sshCommandAnnotation]; ok {
-		r.SSHCommand = val
+		rendered, err := a.render(sshCommandAnnotation, val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to render SSH command", "err", err, "template", val)
+			return r, err
+		}
+		r.SSHCommand = rendered
+	}
+	if val, ok := a.Alert.Annotations[sshCredentialProvider]; ok {
+		r.SSHCredentialProvider = val
+	}
+	if r.SSHCredentialProvider != "" {
+		p, ok := c.CredentialProvider(r.SSHCredentialProvider)
+		if !ok {
+			err := fmt.Errorf("Unknown SSH credential provider: %s", r.SSHCredentialProvider)
+			level.Error(a.logger).Log("msg", "Unable to find SSH credential provider", "err", err)
+			return r, err
+		}
+		r.credentialProvider = p
 	}
 	if val, ok := a.Alert.Annotations[sshConnTimeout]; ok {
 		timeout, err := time.ParseDuration(val)
@@ -164,9 +551,139 @@ func (a *Alert) buildResponse(c *config.Config) (AlertResponse, error) {
 			return r, err
 		}
 	}
+	if val, ok := a.Alert.Annotations[dryRunAnnotation]; ok {
+		dryRun, err := strconv.ParseBool(val)
+		if err == nil {
+			r.DryRun = dryRun
+		} else {
+			level.Error(a.logger).Log("msg", "Unable to parse dry run", "err", err, "dry_run", val)
+			return r, err
+		}
+	}
+	if val, ok := a.Alert.Annotations[notifiersAnnotation]; ok {
+		r.Notifiers = strings.Split(val, ",")
+	}
+	for _, name := range r.Notifiers {
+		n, ok := c.Notifier(name)
+		if !ok {
+			err := fmt.Errorf("Unknown notifier: %s", name)
+			level.Error(a.logger).Log("msg", "Unable to find notifier", "err", err)
+			return r, err
+		}
+		r.notifiers = append(r.notifiers, n)
+	}
+	if val, ok := a.Alert.Annotations[notifyOnAnnotation]; ok {
+		r.NotifyOn = strings.Split(val, ",")
+		if err := config.ValidateNotifyOn(notifyOnAnnotation, r.NotifyOn); err != nil {
+			level.Error(a.logger).Log("msg", "Unable to parse notify on", "err", err)
+			return r, err
+		}
+	}
+	if val, ok := a.Alert.Annotations[silenceDuration]; ok {
+		duration, err := time.ParseDuration(val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to parse silence duration", "err", err, "duration", val)
+			return r, err
+		}
+		if c.Alertmanager.URL == "" {
+			err := errors.New("Silences require alertmanager url in configuration")
+			level.Error(a.logger).Log("msg", "Unable to create silences", "err", err)
+			return r, err
+		}
+		r.SilenceDuration = duration
+	}
+	if val, ok := a.Alert.Annotations[silenceOnAnnotation]; ok {
+		r.SilenceOn = strings.Split(val, ",")
+	} else {
+		r.SilenceOn = []string{"success"}
+	}
+	if val, ok := a.Alert.Annotations[silenceMatchers]; ok {
+		r.SilenceMatchers = strings.Split(val, ",")
+	}
+	if val, ok := a.Alert.Annotations[silenceExpireOnResolve]; ok {
+		expire, err := strconv.ParseBool(val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to parse silence expire on resolve", "err", err, "value", val)
+			return r, err
+		}
+		if expire && c.Alertmanager.URL == "" {
+			err := errors.New("Silences require alertmanager url in configuration")
+			level.Error(a.logger).Log("msg", "Unable to expire silences", "err", err)
+			return r, err
+		}
+		r.SilenceExpire = expire
+	}
+	if val, ok := a.Alert.Annotations[grafanaAnnotation]; ok {
+		annotate, err := strconv.ParseBool(val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to parse Grafana annotate", "err", err, "value", val)
+			return r, err
+		}
+		r.GrafanaAnnotate = annotate && c.Grafana.URL != ""
+	}
+	if val, ok := a.Alert.Annotations[receiversAnnotation]; ok {
+		r.Receivers = strings.Split(val, ",")
+	}
+	r.Mode = a.mode()
+	if r.Mode != "alert" && r.Mode != "group" {
+		err := fmt.Errorf("Unknown mode: %s", r.Mode)
+		level.Error(a.logger).Log("msg", "Unable to parse mode", "err", err)
+		return r, err
+	}
 	if val, ok := a.Alert.Annotations[localCommandAnnotation]; ok {
-		r.LocalCommand = val
+		rendered, err := a.render(localCommandAnnotation, val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to render local command", "err", err, "template", val)
+			return r, err
+		}
+		r.LocalCommand = rendered
+	}
+	if val, ok := a.Alert.Annotations[cleanupSSHCommand]; ok {
+		rendered, err := a.render(cleanupSSHCommand, val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to render cleanup SSH command", "err", err, "template", val)
+			return r, err
+		}
+		r.CleanupSSHCommand = rendered
+	}
+	if val, ok := a.Alert.Annotations[cleanupLocalCommand]; ok {
+		rendered, err := a.render(cleanupLocalCommand, val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to render cleanup local command", "err", err, "template", val)
+			return r, err
+		}
+		r.CleanupLocalCommand = rendered
+	}
+	if val, ok := a.Alert.Annotations[minFiringDuration]; ok {
+		duration, err := time.ParseDuration(val)
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to parse min firing duration", "err", err, "duration", val)
+			return r, err
+		}
+		r.MinFiringDuration = duration
+	}
+	escalations, err := a.escalations(r.MinFiringDuration)
+	if err != nil {
+		level.Error(a.logger).Log("msg", "Unable to parse escalations", "err", err)
+		return r, err
+	}
+	r.Escalations = escalations
+	if val, ok := a.Alert.Annotations[scheduleAnnotation]; ok {
+		s, ok := c.Schedule(val)
+		if !ok {
+			err := fmt.Errorf("Unknown schedule: %s", val)
+			level.Error(a.logger).Log("msg", "Unable to find schedule", "err", err)
+			return r, err
+		}
+		r.Schedule = val
+		r.schedule = s
+	}
+	limits, err := a.breakerLimits()
+	if err != nil {
+		level.Error(a.logger).Log("msg", "Unable to parse circuit breaker", "err", err)
+		return r, err
 	}
+	r.Breaker = limits
 	if val, ok := a.Alert.Annotations[localCommandTimeout]; ok {
 		timeout, err := time.ParseDuration(val)
 		if err == nil {
@@ -14,12 +14,18 @@
 package alert
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/alertmanager/template"
 	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/notify"
 	"github.com/treydock/alertmanager-command-responder/internal/utils"
 )
 
@@ -39,13 +45,29 @@ func TestName(t *testing.T) {
 	}
 }
 
-func TestBuildResponse(t *testing.T) {
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser: "test",
-			SSHKey:  "ssh_key",
-		},
+func TestNewManualAlert(t *testing.T) {
+	alert := NewManualAlert("restart", "", template.KV{"host": "foo", "alertname": "bar"}, nil)
+	if alert.Name() != "restart" {
+		t.Errorf("Unexpected value for name, got: %s", alert.Name())
+	}
+	if alert.Status != "firing" {
+		t.Errorf("Unexpected value for status, got: %s", alert.Status)
 	}
+	if alert.Labels["host"] != "foo" {
+		t.Errorf("Unexpected value for host label, got: %s", alert.Labels["host"])
+	}
+	other := NewManualAlert("restart", "resolved", template.KV{"host": "foo"}, nil)
+	if alert.Fingerprint == "" || alert.Fingerprint != other.Fingerprint {
+		t.Errorf("Unexpected fingerprint, got: %s and %s", alert.Fingerprint, other.Fingerprint)
+	}
+}
+
+func TestBuildResponse(t *testing.T) {
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+		SSHKey:  "ssh_key",
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	alert := &Alert{
@@ -55,7 +77,7 @@ func TestBuildResponse(t *testing.T) {
 		},
 		logger: logger,
 	}
-	r, err := alert.buildResponse(sc.C)
+	r, err := alert.buildResponse(sc.Config())
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err)
 	}
@@ -79,8 +101,9 @@ func TestBuildResponse(t *testing.T) {
 		"cr_ssh_cmd_timeout":   "10s",
 		"cr_local_cmd":         "hostname",
 		"cr_local_cmd_timeout": "15s",
+		"cr_dry_run":           "true",
 	}
-	r, err = alert.buildResponse(sc.C)
+	r, err = alert.buildResponse(sc.Config())
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err)
 	}
@@ -114,15 +137,20 @@ func TestBuildResponse(t *testing.T) {
 	if r.LocalCommandTimeout.Seconds() != 15 {
 		t.Errorf("Unexpected value for LocalCommandTimeout, got %f", r.LocalCommandTimeout.Seconds())
 	}
+	if !r.DryRun {
+		t.Errorf("Unexpected value for DryRun, got %v", r.DryRun)
+	}
+	if r.authMethod() != "certificate" {
+		t.Errorf("Unexpected value for authMethod, got %s", r.authMethod())
+	}
 }
 
 func TestBuildResponseErrors(t *testing.T) {
-	sc := &config.SafeConfig{
-		C: &config.Config{
-			SSHUser: "test",
-			SSHKey:  "ssh_key",
-		},
-	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		SSHUser: "test",
+		SSHKey:  "ssh_key",
+	})
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
 	alert := &Alert{
@@ -135,22 +163,151 @@ func TestBuildResponseErrors(t *testing.T) {
 		},
 		logger: logger,
 	}
-	_, err := alert.buildResponse(sc.C)
+	_, err := alert.buildResponse(sc.Config())
 	if err == nil {
 		t.Errorf("Expected an error")
 	}
 	alert.Alert.Annotations = map[string]string{
 		"cr_ssh_cmd_timeout": "foo",
 	}
-	_, err = alert.buildResponse(sc.C)
+	_, err = alert.buildResponse(sc.Config())
 	if err == nil {
 		t.Errorf("Expected an error")
 	}
 	alert.Alert.Annotations = map[string]string{
 		"cr_local_cmd_timeout": "foo",
 	}
-	_, err = alert.buildResponse(sc.C)
+	_, err = alert.buildResponse(sc.Config())
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+	alert.Alert.Annotations = map[string]string{
+		"cr_dry_run": "foo",
+	}
+	_, err = alert.buildResponse(sc.Config())
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+	alert.Alert.Annotations = map[string]string{
+		"cr_notifiers": "dne",
+	}
+	_, err = alert.buildResponse(sc.Config())
 	if err == nil {
 		t.Errorf("Expected an error")
 	}
+	alert.Alert.Annotations = map[string]string{
+		"cr_notify_on": "failure,fail",
+	}
+	_, err = alert.buildResponse(sc.Config())
+	if err == nil || err.Error() != "Unsupported cr_notify_on event: fail" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	alert.Alert.Annotations = map[string]string{
+		"cr_mode": "foo",
+	}
+	_, err = alert.buildResponse(sc.Config())
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+	alert.Alert.Annotations = map[string]string{
+		"cr_ssh_cmd": "{{ .Labels.dne }}",
+	}
+	_, err = alert.buildResponse(sc.Config())
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestHandleAlertNotify(t *testing.T) {
+	received := make(chan notify.Message, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Unexpected error decoding body: %s", err)
+		}
+		received <- msg
+	}))
+	defer server.Close()
+	c := &config.Config{
+		LocalCommandTimeout: time.Second,
+		Notifiers: []config.Notifier{
+			{Name: "webhook", Type: "webhook", URL: config.Secret(server.URL), Timeout: time.Second},
+		},
+		NotifyOn: []string{"failure", "timeout"},
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	tests := []struct {
+		command string
+		outcome string
+	}{
+		{command: "true", outcome: ""},
+		{command: "false", outcome: "failure"},
+		{command: "sleep 2", outcome: "timeout"},
+	}
+	for _, test := range tests {
+		alert := &Alert{
+			Alert: template.Alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "foo"},
+				Annotations: map[string]string{
+					"cr_local_cmd": test.command,
+					"cr_notifiers": "webhook",
+				},
+				Fingerprint: "bar",
+			},
+		}
+		_ = alert.HandleAlert(context.Background(), c, logger)
+		select {
+		case msg := <-received:
+			if msg.Outcome != test.outcome {
+				t.Errorf("Unexpected outcome for %s, expected %s got %s", test.command, test.outcome, msg.Outcome)
+			}
+			if msg.Responder != "foo" {
+				t.Errorf("Unexpected responder %s", msg.Responder)
+			}
+		default:
+			if test.outcome != "" {
+				t.Errorf("No notification sent for %s", test.command)
+			}
+		}
+	}
+}
+
+func TestHandleAlertSimulate(t *testing.T) {
+	received := make(chan notify.Message, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- notify.Message{}
+	}))
+	defer server.Close()
+	c := &config.Config{
+		LocalCommandTimeout: time.Second,
+		Notifiers: []config.Notifier{
+			{Name: "webhook", Type: "webhook", URL: config.Secret(server.URL), Timeout: time.Second},
+		},
+		NotifyOn: []string{"failure"},
+	}
+	alert := &Alert{
+		Alert: template.Alert{
+			Status: "firing",
+			Labels: map[string]string{"alertname": "foo"},
+			Annotations: map[string]string{
+				"cr_local_cmd": "false",
+				"cr_notifiers": "webhook",
+			},
+			Fingerprint: "simulate",
+		},
+		Simulate: true,
+	}
+	if err := alert.HandleAlert(context.Background(), c, log.NewNopLogger()); err == nil {
+		t.Errorf("Expected command error")
+	}
+	if len(alert.Results) != 1 || alert.Results[0].DryRun {
+		t.Errorf("Command was not executed: %v", alert.Results)
+	}
+	select {
+	case <-received:
+		t.Errorf("Notification sent for simulated alert")
+	default:
+	}
 }
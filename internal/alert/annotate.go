@@ -0,0 +1,75 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/log/level"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/grafana"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+)
+
+// annotate posts a Grafana annotation for each command that was run.
+func (a *Alert) annotate(c *config.Config) {
+	client := grafana.NewClient(c.Grafana)
+	now := time.Now()
+	for _, result := range a.Results {
+		outcome := result.outcome()
+		tags := []string{
+			"alertname:" + a.Alert.Labels["alertname"],
+			"responder:" + a.Name(),
+			"type:" + result.Type,
+			"result:" + outcome,
+		}
+		if result.Host != "" {
+			tags = append(tags, "host:"+result.Host)
+		}
+		start := now.Add(-time.Duration(result.Duration * float64(time.Second)))
+		annotation := grafana.Annotation{
+			Time:    start.UnixMilli(),
+			TimeEnd: now.UnixMilli(),
+			Tags:    tags,
+			Text:    annotationText(a.Name(), result, outcome, c.Grafana.MaxTextSize),
+		}
+		if _, err := client.CreateAnnotation(context.Background(), annotation); err != nil {
+			level.Error(a.logger).Log("msg", "Unable to create Grafana annotation", "err", err)
+			metrics.ErrorsTotal.Inc()
+		}
+	}
+}
+
+func annotationText(name string, result CommandResult, outcome string, size int) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s %s command %s: %s\n", name, result.Type, outcome, result.Command)
+	if result.Error != "" {
+		fmt.Fprintf(&text, "error: %s\n", result.Error)
+	}
+	text.WriteString(result.Stdout)
+	text.WriteString(result.Stderr)
+	out := strings.TrimSpace(text.String())
+	if size > 0 && len(out) > size {
+		// Do not split a multi-byte character
+		for size > 0 && !utf8.RuneStart(out[size]) {
+			size--
+		}
+		out = out[:size] + "..."
+	}
+	return out
+}
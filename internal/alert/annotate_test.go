@@ -0,0 +1,127 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/grafana"
+)
+
+func TestAnnotate(t *testing.T) {
+	received := make(chan grafana.Annotation, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Unexpected authorization header %s", r.Header.Get("Authorization"))
+		}
+		var annotation grafana.Annotation
+		if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+			t.Errorf("Unexpected error decoding annotation: %s", err)
+		}
+		received <- annotation
+		_, _ = w.Write([]byte(`{"id": 1, "message": "Annotation added"}`))
+	}))
+	defer server.Close()
+	c := &config.Config{
+		LocalCommandTimeout: time.Second,
+		Grafana: config.GrafanaConfig{
+			URL:          server.URL,
+			Token:        "token",
+			Timeout:      time.Second,
+			DashboardUID: "abc",
+			Tags:         []string{"remediation"},
+			MaxTextSize:  40,
+		},
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	alert := &Alert{
+		Alert: template.Alert{
+			Status:      "firing",
+			Labels:      map[string]string{"alertname": "foo"},
+			Annotations: map[string]string{"cr_local_cmd": "echo hello world"},
+			Fingerprint: "bar",
+		},
+	}
+	if err := alert.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	select {
+	case annotation := <-received:
+		expectedTags := []string{"alertname:foo", "responder:foo", "type:local", "result:success", "remediation"}
+		if !reflect.DeepEqual(annotation.Tags, expectedTags) {
+			t.Errorf("Unexpected tags\nExpected: %v\nGot: %v", expectedTags, annotation.Tags)
+		}
+		if annotation.DashboardUID != "abc" {
+			t.Errorf("Unexpected dashboard UID %s", annotation.DashboardUID)
+		}
+		expectedText := "foo local command success: echo hello wo..."
+		if annotation.Text != expectedText {
+			t.Errorf("Unexpected text\nExpected: %s\nGot: %s", expectedText, annotation.Text)
+		}
+		if annotation.Time == 0 || annotation.TimeEnd < annotation.Time {
+			t.Errorf("Unexpected time range %d-%d", annotation.Time, annotation.TimeEnd)
+		}
+	default:
+		t.Errorf("No annotation created")
+	}
+
+	alert.Results = nil
+	alert.Annotations["cr_grafana_annotate"] = "false"
+	if err := alert.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	select {
+	case <-received:
+		t.Errorf("Unexpected annotation created")
+	default:
+	}
+}
+
+func TestAnnotationText(t *testing.T) {
+	result := CommandResult{Type: "local", Command: "echo", Stdout: "ééé"}
+	// "foo local command success: echo\n" is 32 bytes and each é is 2 bytes
+	tests := []struct {
+		size     int
+		expected string
+	}{
+		{size: 0, expected: "foo local command success: echo\nééé"},
+		{size: 34, expected: "foo local command success: echo\né..."},
+		{size: 35, expected: "foo local command success: echo\né..."},
+		{size: 36, expected: "foo local command success: echo\néé..."},
+	}
+	for _, test := range tests {
+		text := annotationText("foo", result, "success", test.size)
+		if text != test.expected {
+			t.Errorf("Unexpected text for size %d, expected %q got %q", test.size, test.expected, text)
+		}
+		if !utf8.ValidString(text) {
+			t.Errorf("Invalid UTF-8 for size %d: %q", test.size, text)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/treydock/alertmanager-command-responder/internal/breaker"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/notify"
+)
+
+const defaultBreakerWindow = 10 * time.Minute
+
+// breakerLimits parses the circuit breaker limits, the window defaults to 10 minutes.
+func (a *Alert) breakerLimits() (breaker.Limits, error) {
+	l := breaker.Limits{Window: defaultBreakerWindow}
+	if val, ok := a.Alert.Annotations[breakerWindow]; ok {
+		window, err := time.ParseDuration(val)
+		if err != nil || window <= 0 {
+			return l, fmt.Errorf("Invalid circuit breaker window: %s", val)
+		}
+		l.Window = window
+	}
+	if val, ok := a.Alert.Annotations[breakerMaxHosts]; ok {
+		maxHosts, err := strconv.Atoi(val)
+		if err != nil || maxHosts <= 0 {
+			return l, fmt.Errorf("Invalid circuit breaker max hosts: %s", val)
+		}
+		l.MaxHosts = maxHosts
+	}
+	if val, ok := a.Alert.Annotations[breakerMaxAlerting]; ok {
+		percent, err := strconv.ParseFloat(val, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return l, fmt.Errorf("Invalid circuit breaker max alerting percent: %s", val)
+		}
+		l.MaxAlertingPercent = percent
+		targets, err := strconv.Atoi(a.Alert.Annotations[breakerTargets])
+		if err != nil || targets <= 0 {
+			return l, fmt.Errorf("Circuit breaker max alerting percent requires a positive %s", breakerTargets)
+		}
+		l.Targets = targets
+	}
+	return l, nil
+}
+
+// trackAlerting records whether the alerts are firing for the circuit breaker of the responder,
+// in group mode every alert of the group is recorded.
+func (a *Alert) trackAlerting() {
+	alerts := a.Alerts
+	if len(alerts) == 0 {
+		alerts = append(alerts, a.Alert)
+	}
+	now := time.Now()
+	for _, alert := range alerts {
+		if err := breaker.Alerting(a.Name(), alert.Fingerprint, alert.Status == "firing", now); err != nil {
+			level.Error(a.logger).Log("msg", "Unable to save circuit breakers", "err", err)
+			metrics.ErrorsTotal.Inc()
+		}
+	}
+}
+
+// breakerOpen returns true if the circuit breaker of the responder has tripped or trips because of this alert.
+// Dry runs and simulations only check if the breaker has tripped.
+func (a *Alert) breakerOpen() bool {
+	if a.DryRun || a.Simulate {
+		return breaker.Tripped(a.Name()) != nil
+	}
+	trip, tripped, err := breaker.Check(a.Name(), a.breakerHost(), a.Response.Breaker, time.Now())
+	if err != nil {
+		level.Error(a.logger).Log("msg", "Unable to save circuit breakers", "err", err)
+		metrics.ErrorsTotal.Inc()
+	}
+	if tripped {
+		level.Warn(a.logger).Log("msg", "Circuit breaker tripped", "reason", trip.Reason)
+		a.send(notify.Message{
+			Responder:   a.Name(),
+			Fingerprint: a.Alert.Fingerprint,
+			Status:      a.Alert.Status,
+			Outcome:     "circuit_breaker",
+			Reason:      trip.Reason,
+			Labels:      a.Alert.Labels,
+			Annotations: a.Alert.Annotations,
+		})
+	}
+	return trip != nil
+}
+
+// breakerHost returns the host the commands run on, the instance label or fingerprint is used for local commands.
+func (a *Alert) breakerHost() string {
+	if a.Response.SSHHost != "" && a.Response.SSHCommand != "" {
+		return a.Response.SSHHost
+	}
+	if instance, ok := a.Alert.Labels["instance"]; ok {
+		return instance
+	}
+	return a.Alert.Fingerprint
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/breaker"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/notify"
+)
+
+func TestHandleAlertBreaker(t *testing.T) {
+	received := make(chan notify.Message, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Unexpected error decoding body: %s", err)
+		}
+		received <- msg
+	}))
+	defer server.Close()
+	defer breaker.Reset("breaker")
+	c := &config.Config{
+		LocalCommandTimeout: 2 * time.Second,
+		Notifiers: []config.Notifier{
+			{Name: "webhook", Type: "webhook", URL: config.Secret(server.URL), Timeout: time.Second},
+		},
+		NotifyOn: []string{"failure"},
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	newAlert := func(instance string, annotations template.KV) *Alert {
+		annotations["cr_local_cmd"] = "echo restart"
+		annotations["cr_notifiers"] = "webhook"
+		return &Alert{
+			Alert: template.Alert{
+				Status:      "firing",
+				Labels:      template.KV{"alertname": "breaker", "instance": instance},
+				Annotations: annotations,
+				StartsAt:    time.Now(),
+				Fingerprint: "breaker-" + instance,
+			},
+		}
+	}
+	tests := []struct {
+		instance string
+		skipped  string
+		notified bool
+	}{
+		{instance: "host1"},
+		{instance: "host1"},
+		{instance: "host2", skipped: "circuit_breaker", notified: true},
+		{instance: "host1", skipped: "circuit_breaker"},
+	}
+	for i, test := range tests {
+		a := newAlert(test.instance, template.KV{"cr_breaker_max_hosts": "1", "cr_breaker_window": "1h"})
+		if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+			t.Fatalf("Unexpected error in step %d: %s", i, err)
+		}
+		if a.Skipped != test.skipped || (test.skipped == "") != (len(a.Results) == 1) {
+			t.Errorf("Unexpected step %d: skipped=%s results=%+v", i, a.Skipped, a.Results)
+		}
+		select {
+		case msg := <-received:
+			if !test.notified || msg.Outcome != "circuit_breaker" || msg.Reason != "more than 1 hosts in 1h0m0s" {
+				t.Errorf("Unexpected notification in step %d: %+v", i, msg)
+			}
+		default:
+			if test.notified {
+				t.Errorf("Expected notification in step %d", i)
+			}
+		}
+	}
+
+	invalid := []template.KV{
+		{"cr_breaker_max_hosts": "0"},
+		{"cr_breaker_max_hosts": "1", "cr_breaker_window": "foo"},
+		{"cr_breaker_max_alerting_percent": "50"},
+		{"cr_breaker_max_alerting_percent": "150", "cr_breaker_targets": "10"},
+	}
+	for _, annotations := range invalid {
+		a := newAlert("host1", annotations)
+		if err := a.HandleAlert(context.Background(), c, logger); err == nil {
+			t.Errorf("Expected an error for %v", annotations)
+		}
+	}
+}
@@ -19,7 +19,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net"
-	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -27,13 +26,26 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/treydock/alertmanager-command-responder/internal/credentials"
 	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
 
-func (r *AlertResponse) runLocalCommand(logger log.Logger) error {
+func (r *AlertResponse) runLocalCommand(ctx context.Context, logger log.Logger) (CommandResult, error) {
+	_, span := tracing.Tracer().Start(ctx, "exec.Command", trace.WithAttributes(
+		attribute.String("command", r.LocalCommand),
+	))
+	defer span.End()
 	var stdout, stderr bytes.Buffer
+	result := CommandResult{
+		Type:    "local",
+		Command: r.LocalCommand,
+	}
 	localCmd := strings.Split(r.LocalCommand, " ")
 	cmdName := localCmd[0]
 	var cmdArgs []string
@@ -41,44 +53,97 @@ func (r *AlertResponse) runLocalCommand(logger log.Logger) error {
 		cmdArgs = localCmd[1:]
 	}
 	level.Info(logger).Log("msg", "Running local command", "command", cmdName, "args", strings.Join(cmdArgs, " "))
-	ctx, cancel := context.WithTimeout(context.Background(), r.LocalCommandTimeout)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), r.LocalCommandTimeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, cmdName, cmdArgs...)
+	cmd := exec.CommandContext(timeoutCtx, cmdName, cmdArgs...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	err := cmd.Run()
-	if ctx.Err() == context.DeadlineExceeded {
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if timeoutCtx.Err() == context.DeadlineExceeded {
 		level.Error(logger).Log("msg", "Local command timed out")
-		return fmt.Errorf("Local command timed out: %s", r.LocalCommand)
+		err = fmt.Errorf("Local command timed out: %s", r.LocalCommand)
+		result.TimedOut = true
+		result.Error = err.Error()
+		spanError(span, err)
+		return result, err
 	} else if err != nil {
 		level.Error(logger).Log("msg", "Error executing command", "err", err)
-		return err
+		result.Error = err.Error()
+		spanError(span, err)
+		return result, err
 	}
-	level.Info(logger).Log("msg", "Local command completed", "out", stdout.String(), "err", stderr.String())
-	return nil
+	level.Info(logger).Log("msg", "Local command completed", "out", result.Stdout, "err", result.Stderr)
+	return result, nil
 }
 
-func (r *AlertResponse) runSSHCommand(logger log.Logger) error {
+func (r *AlertResponse) runSSHCommand(ctx context.Context, logger log.Logger) (CommandResult, error) {
 	level.Info(logger).Log("msg", "Running SSH command")
+	ctx, span := tracing.Tracer().Start(ctx, "runSSHCommand", trace.WithAttributes(
+		attribute.String("ssh.host", r.SSHHost),
+		attribute.String("ssh.user", r.SSHUser),
+		attribute.String("command", r.SSHCommand),
+	))
+	defer span.End()
 	c1 := make(chan int, 1)
 	var auth ssh.AuthMethod
 	var err, sessionerror, commanderror error
 	var stdout, stderr bytes.Buffer
+	result := CommandResult{
+		Type:    "ssh",
+		Command: r.SSHCommand,
+		Host:    r.SSHHost,
+	}
 
-	if r.SSHCertificate != "" {
-		auth, err = getCertificateAuth(r.SSHKey, r.SSHCertificate)
+	provider, err := credentials.New(r.credentialProvider)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error setting up credential provider", "err", err)
+		result.Error = err.Error()
+		spanError(span, err)
+		return result, err
+	}
+	creds, err := credentials.Get(ctx, provider, r.credentialProvider.CacheTTL, credentials.Request{
+		User:        r.SSHUser,
+		Host:        r.SSHHost,
+		Key:         r.SSHKey,
+		Certificate: r.SSHCertificate,
+		Password:    r.SSHPassword,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "Error getting SSH credentials", "err", err)
+		result.Error = err.Error()
+		spanError(span, err)
+		return result, err
+	}
+	if r.credentialProvider.Type == "ca" && len(creds.Certificate) == 0 {
+		err = fmt.Errorf("Credential provider %s did not return a certificate", r.credentialProvider.Name)
+		level.Error(logger).Log("msg", "Error getting SSH credentials", "err", err)
+		result.Error = err.Error()
+		spanError(span, err)
+		return result, err
+	}
+	result.Auth = credentialsAuthMethod(creds)
+	result.Identity = credentialsIdentity(creds)
+	switch result.Auth {
+	case "certificate":
+		auth, err = getCertificateAuth(creds.PrivateKey, creds.Certificate)
 		if err != nil {
 			level.Error(logger).Log("msg", "Error setting up certificate auth", "err", err)
-			return err
+			result.Error = err.Error()
+			spanError(span, err)
+			return result, err
 		}
-	} else if r.SSHKey != "" {
-		auth, err = getPrivateKeyAuth(r.SSHKey)
+	case "key":
+		auth, err = getPrivateKeyAuth(creds.PrivateKey)
 		if err != nil {
 			level.Error(logger).Log("msg", "Error setting up private key auth", "err", err)
-			return err
+			result.Error = err.Error()
+			spanError(span, err)
+			return result, err
 		}
-	} else if r.SSHPassword != "" {
-		auth = ssh.Password(r.SSHPassword)
+	case "password":
+		auth = ssh.Password(string(creds.Password))
 	}
 	level.Debug(logger).Log("msg", "Dial SSH", "timeout", r.SSHConnectionTimeout*time.Second)
 	sshConfig := &ssh.ClientConfig{
@@ -88,13 +153,21 @@ func (r *AlertResponse) runSSHCommand(logger log.Logger) error {
 		HostKeyAlgorithms: r.SSHHostKeyAlgorithms,
 		Timeout:           r.SSHConnectionTimeout,
 	}
+	_, dialSpan := tracing.Tracer().Start(ctx, "ssh.Dial")
 	connection, err := ssh.Dial("tcp", r.SSHHost, sshConfig)
 	if err != nil {
 		level.Error(logger).Log("msg", "Failed to establish SSH connection", "err", err)
-		return err
+		result.Error = err.Error()
+		spanError(dialSpan, err)
+		dialSpan.End()
+		spanError(span, err)
+		return result, err
 	}
+	dialSpan.End()
 	defer connection.Close()
 
+	_, sessionSpan := tracing.Tracer().Start(ctx, "ssh.Session.Run")
+	defer sessionSpan.End()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go func(conn *ssh.Client) {
@@ -119,58 +192,115 @@ func (r *AlertResponse) runSSHCommand(logger log.Logger) error {
 	case <-time.After(r.SSHCommandTimeout):
 		close(c1)
 		level.Error(logger).Log("msg", "Timeout executing SSH command")
-		return fmt.Errorf("Timeout executing SSH command: %s", r.SSHCommand)
+		err = fmt.Errorf("Timeout executing SSH command: %s", r.SSHCommand)
+		result.TimedOut = true
+		result.Error = err.Error()
+		spanError(sessionSpan, err)
+		spanError(span, err)
+		return result, err
 	}
 	close(c1)
 
 	if sessionerror != nil {
 		level.Error(logger).Log("msg", "Failed to establish SSH session", "err", sessionerror)
-		return sessionerror
+		result.Error = sessionerror.Error()
+		spanError(sessionSpan, sessionerror)
+		spanError(span, sessionerror)
+		return result, sessionerror
 	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
 	if commanderror != nil {
 		level.Error(logger).Log("msg", "Failed to run SSH command", "err", commanderror)
-		return commanderror
+		result.Error = commanderror.Error()
+		spanError(sessionSpan, commanderror)
+		spanError(span, commanderror)
+		return result, commanderror
 	}
-	level.Info(logger).Log("msg", "SSH command completed", "out", stdout.String(), "err", stderr.String())
-	return nil
+	level.Info(logger).Log("msg", "SSH command completed", "out", result.Stdout, "err", result.Stderr)
+	return result, nil
 }
 
-func getPrivateKeyAuth(privatekey string) (ssh.AuthMethod, error) {
-	buffer, err := os.ReadFile(privatekey)
-	if err != nil {
-		return nil, err
+func spanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// authMethod returns which SSH authentication method will be used without retrieving credentials.
+func (r *AlertResponse) authMethod() string {
+	if r.SSHCredentialProvider != "" {
+		return fmt.Sprintf("provider %s", r.SSHCredentialProvider)
 	}
-	key, err := ssh.ParsePrivateKey(buffer)
-	if err != nil {
-		return nil, err
+	if r.SSHCertificate != "" {
+		return "certificate"
+	} else if r.SSHKey != "" {
+		return "key"
+	} else if r.SSHPassword != "" {
+		return "password"
 	}
-	return ssh.PublicKeys(key), nil
+	return "none"
 }
 
-func getCertificateAuth(privatekey string, certificate string) (ssh.AuthMethod, error) {
-	key, err := os.ReadFile(privatekey)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to read private key: '%s' %v", privatekey, err)
+func credentialsAuthMethod(creds credentials.Credentials) string {
+	if len(creds.Certificate) > 0 {
+		return "certificate"
+	} else if len(creds.PrivateKey) > 0 {
+		return "key"
+	} else if creds.Password != "" {
+		return "password"
 	}
+	return "none"
+}
 
-	// Create the Signer for this private key.
-	signer, err := ssh.ParsePrivateKey(key)
+// credentialsIdentity describes the identity of the credentials without revealing secrets,
+// the SHA256 fingerprint of the key and the key ID of certificates.
+func credentialsIdentity(creds credentials.Credentials) string {
+	if len(creds.Certificate) > 0 {
+		pk, _, _, _, err := ssh.ParseAuthorizedKey(creds.Certificate)
+		if err != nil {
+			return ""
+		}
+		if cert, ok := pk.(*ssh.Certificate); ok {
+			return fmt.Sprintf("%s %s", ssh.FingerprintSHA256(cert.Key), cert.KeyId)
+		}
+		return ssh.FingerprintSHA256(pk)
+	} else if len(creds.PrivateKey) > 0 {
+		signer, err := ssh.ParsePrivateKey(creds.PrivateKey)
+		if err != nil {
+			return ""
+		}
+		return ssh.FingerprintSHA256(signer.PublicKey())
+	} else if creds.Password != "" {
+		return "password"
+	}
+	return ""
+}
+
+func getPrivateKeyAuth(privatekey []byte) (ssh.AuthMethod, error) {
+	key, err := ssh.ParsePrivateKey(privatekey)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to parse private key: '%s' %v", privatekey, err)
+		return nil, err
 	}
+	return ssh.PublicKeys(key), nil
+}
 
-	// Load the certificate
-	cert, err := os.ReadFile(certificate)
+func getCertificateAuth(privatekey []byte, certificate []byte) (ssh.AuthMethod, error) {
+	// Create the Signer for this private key.
+	signer, err := ssh.ParsePrivateKey(privatekey)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to read certificate file: '%s' %v", certificate, err)
+		return nil, fmt.Errorf("Unable to parse private key: %v", err)
 	}
 
-	pk, _, _, _, err := ssh.ParseAuthorizedKey(cert)
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(certificate)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to parse public key: '%s' %v", certificate, err)
+		return nil, fmt.Errorf("Unable to parse public key: %v", err)
 	}
 
-	certSigner, err := ssh.NewCertSigner(pk.(*ssh.Certificate), signer)
+	cert, ok := pk.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("Public key is not a certificate")
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to create cert signer: %v", err)
 	}
@@ -0,0 +1,73 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/state"
+)
+
+// episodeAlerts returns the alerts whose firing episodes are tracked, the combined alerts of a group or the alert itself.
+func (a *Alert) episodeAlerts() template.Alerts {
+	if len(a.Alerts) > 0 {
+		return a.Alerts
+	}
+	return template.Alerts{a.Alert}
+}
+
+// recordExecution adds the outcome of the commands to the firing episode of the alert.
+func (a *Alert) recordExecution(outcome string) {
+	for _, e := range a.episodeAlerts() {
+		err := state.RecordExecution(e.Fingerprint, a.Name(), e.StartsAt, state.Execution{
+			Time:    time.Now(),
+			Outcome: outcome,
+			Level:   a.Level,
+		})
+		if err != nil {
+			level.Error(a.logger).Log("msg", "Unable to save alert state", "err", err)
+			metrics.ErrorsTotal.Inc()
+		}
+	}
+}
+
+// endEpisode removes the firing episode of the alert once it resolves.
+func (a *Alert) endEpisode() {
+	for _, e := range a.episodeAlerts() {
+		if err := state.EndEpisode(e.Fingerprint); err != nil {
+			level.Error(a.logger).Log("msg", "Unable to save alert state", "err", err)
+			metrics.ErrorsTotal.Inc()
+		}
+	}
+}
+
+// cleanupSkipReason returns why cleanup commands should not run for the resolved alert,
+// cleanup only runs when a command succeeded during the same firing episode of one of the alerts.
+func (a *Alert) cleanupSkipReason() string {
+	reason := "firing_not_run"
+	for _, alert := range a.episodeAlerts() {
+		e, ok := state.Get(alert.Fingerprint)
+		if !ok || !e.StartsAt.Equal(alert.StartsAt) || len(e.Executions) == 0 {
+			continue
+		}
+		if e.Succeeded() {
+			return ""
+		}
+		reason = "firing_failed"
+	}
+	return reason
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/state"
+)
+
+func TestHandleAlertCleanup(t *testing.T) {
+	c := &config.Config{LocalCommandTimeout: 2 * time.Second}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	startsAt := time.Now().Add(-time.Minute)
+	newAlert := func(status string, command string, fingerprint string) *Alert {
+		return &Alert{
+			Alert: template.Alert{
+				Status: status,
+				Labels: template.KV{"alertname": "drain"},
+				Annotations: template.KV{
+					"cr_local_cmd":         command,
+					"cr_cleanup_local_cmd": "echo resume",
+				},
+				StartsAt:    startsAt,
+				Fingerprint: fingerprint,
+			},
+		}
+	}
+	tests := []struct {
+		fingerprint string
+		command     string
+		skipped     string
+	}{
+		{fingerprint: "cleanup-success", command: "echo drain", skipped: ""},
+		{fingerprint: "cleanup-failed", command: "false", skipped: "firing_failed"},
+		{fingerprint: "cleanup-not-run", command: "", skipped: "firing_not_run"},
+	}
+	for _, test := range tests {
+		if test.command != "" {
+			firing := newAlert("firing", test.command, test.fingerprint)
+			_ = firing.HandleAlert(context.Background(), c, logger)
+			if len(firing.Results) != 1 || firing.Results[0].Command != test.command {
+				t.Errorf("Unexpected firing results for %s: %+v", test.fingerprint, firing.Results)
+			}
+		}
+		resolved := newAlert("resolved", test.command, test.fingerprint)
+		if err := resolved.HandleAlert(context.Background(), c, logger); err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.fingerprint, err)
+		}
+		if resolved.Skipped != test.skipped {
+			t.Errorf("Unexpected skipped for %s, expected %s got %s", test.fingerprint, test.skipped, resolved.Skipped)
+		}
+		if test.skipped == "" && (len(resolved.Results) != 1 || resolved.Results[0].Stdout != "resume\n") {
+			t.Errorf("Unexpected cleanup results for %s: %+v", test.fingerprint, resolved.Results)
+		}
+		if _, ok := state.Get(test.fingerprint); ok {
+			t.Errorf("Expected episode of %s to end", test.fingerprint)
+		}
+	}
+
+	// Cleanup is skipped for a different firing episode
+	firing := newAlert("firing", "echo drain", "cleanup-episode")
+	_ = firing.HandleAlert(context.Background(), c, logger)
+	resolved := newAlert("resolved", "echo drain", "cleanup-episode")
+	resolved.Alert.StartsAt = time.Now()
+	_ = resolved.HandleAlert(context.Background(), c, logger)
+	if resolved.Skipped != "firing_not_run" {
+		t.Errorf("Unexpected skipped, got %s", resolved.Skipped)
+	}
+}
+
+func TestHandleAlertGroupCleanup(t *testing.T) {
+	c := &config.Config{LocalCommandTimeout: 2 * time.Second}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	startsAt := time.Now().Add(-time.Minute)
+	newAlert := func(status string, host string) Alert {
+		return Alert{
+			Alert: template.Alert{
+				Status: status,
+				Labels: template.KV{"alertname": "drain-group", "host": host},
+				Annotations: template.KV{
+					"cr_mode":              "group",
+					"cr_local_cmd":         "echo drain",
+					"cr_cleanup_local_cmd": "echo resume",
+				},
+				StartsAt:    startsAt,
+				Fingerprint: "group-" + host,
+			},
+		}
+	}
+	firing := GroupAlerts([]Alert{newAlert("firing", "node1"), newAlert("firing", "node2")})
+	if len(firing) != 1 {
+		t.Fatalf("Unexpected groups: %+v", firing)
+	}
+	if err := firing[0].HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// A notification with firing and resolved alerts runs the cleanup for the resolved alerts
+	grouped := GroupAlerts([]Alert{newAlert("firing", "node1"), newAlert("resolved", "node2")})
+	if len(grouped) != 2 || grouped[0].Status != "firing" || grouped[1].Status != "resolved" {
+		t.Fatalf("Unexpected groups: %+v", grouped)
+	}
+	resolved := grouped[1]
+	if len(resolved.Alerts) != 1 || resolved.Alerts[0].Fingerprint != "group-node2" {
+		t.Fatalf("Unexpected resolved alerts: %+v", resolved.Alerts)
+	}
+	if err := resolved.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if resolved.Skipped != "" || len(resolved.Results) != 1 || resolved.Results[0].Stdout != "resume\n" {
+		t.Errorf("Unexpected cleanup results, skipped %s: %+v", resolved.Skipped, resolved.Results)
+	}
+	if _, ok := state.Get("group-node2"); ok {
+		t.Errorf("Expected episode of the resolved alert to end")
+	}
+	if _, ok := state.Get("group-node1"); !ok {
+		t.Errorf("Expected episode of the firing alert to be kept")
+	}
+	_ = state.EndEpisode("group-node1")
+}
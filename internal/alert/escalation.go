@@ -0,0 +1,87 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/state"
+)
+
+// escalations parses the escalation levels, each level must start later than the previous level and have a command.
+func (a *Alert) escalations(minFiring time.Duration) ([]Escalation, error) {
+	var escalations []Escalation
+	previous := minFiring
+	for n := 1; ; n++ {
+		val, ok := a.Alert.Annotations[fmt.Sprintf(escalationAfter, n)]
+		if !ok {
+			break
+		}
+		after, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse escalation %d after: %v", n, err)
+		}
+		if after <= previous {
+			return nil, fmt.Errorf("Escalation %d after %s must be longer than the previous level %s", n, after, previous)
+		}
+		previous = after
+		e := Escalation{After: after}
+		for name, command := range map[string]*string{escalationSSHCommand: &e.SSHCommand, escalationLocalCommand: &e.LocalCommand} {
+			name = fmt.Sprintf(name, n)
+			if val, ok := a.Alert.Annotations[name]; ok {
+				rendered, err := a.render(name, val)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to render %s: %v", name, err)
+				}
+				*command = rendered
+			}
+		}
+		if e.SSHCommand == "" && e.LocalCommand == "" {
+			return nil, fmt.Errorf("Escalation %d requires %s or %s", n, fmt.Sprintf(escalationSSHCommand, n), fmt.Sprintf(escalationLocalCommand, n))
+		}
+		escalations = append(escalations, e)
+	}
+	return escalations, nil
+}
+
+// escalate selects the commands for how long the firing alert has been firing and returns why no commands should run.
+// With escalations each level runs once per firing episode, a repeated notification runs the next level once it is due.
+func (a *Alert) escalate() string {
+	r := a.Response
+	firing := time.Since(a.Alert.StartsAt)
+	if firing < r.MinFiringDuration {
+		return "min_firing_duration"
+	}
+	if len(r.Escalations) == 0 {
+		return ""
+	}
+	next := 0
+	if e, ok := state.Get(a.Alert.Fingerprint); ok && e.StartsAt.Equal(a.Alert.StartsAt) {
+		next = e.LastLevel() + 1
+	}
+	if next > len(r.Escalations) {
+		return "escalation_complete"
+	}
+	if next > 0 {
+		e := r.Escalations[next-1]
+		if firing < e.After {
+			return "escalation_pending"
+		}
+		a.Response.SSHCommand = e.SSHCommand
+		a.Response.LocalCommand = e.LocalCommand
+	}
+	a.Level = next
+	return ""
+}
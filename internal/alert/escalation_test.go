@@ -0,0 +1,120 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func TestHandleAlertEscalation(t *testing.T) {
+	c := &config.Config{LocalCommandTimeout: 2 * time.Second}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	annotations := template.KV{
+		"cr_local_cmd":              "echo soft",
+		"cr_min_firing_duration":    "5m",
+		"cr_escalation_1_after":     "10m",
+		"cr_escalation_1_local_cmd": "echo hard",
+		"cr_escalation_2_after":     "1h",
+		"cr_escalation_2_local_cmd": "echo page",
+	}
+	newAlert := func(fingerprint string, firing time.Duration, annotations template.KV) *Alert {
+		return &Alert{
+			Alert: template.Alert{
+				Status:      "firing",
+				Labels:      template.KV{"alertname": "escalate"},
+				Annotations: annotations,
+				StartsAt:    time.Now().Add(-firing).Truncate(time.Second),
+				Fingerprint: fingerprint,
+			},
+		}
+	}
+
+	a := newAlert("escalation-min", time.Minute, annotations)
+	if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Skipped != "min_firing_duration" || len(a.Results) != 0 {
+		t.Errorf("Unexpected alert: skipped=%s results=%+v", a.Skipped, a.Results)
+	}
+
+	startsAt := newAlert("", 20*time.Minute, nil).StartsAt
+	tests := []struct {
+		level   int
+		skipped string
+		stdout  string
+	}{
+		{level: 0, stdout: "soft\n"},
+		{level: 1, stdout: "hard\n"},
+		{level: 0, skipped: "escalation_pending"},
+	}
+	for i, test := range tests {
+		a := newAlert("escalation", 0, annotations)
+		a.Alert.StartsAt = startsAt
+		if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+			t.Fatalf("Unexpected error in step %d: %s", i, err)
+		}
+		if a.Skipped != test.skipped || a.Level != test.level {
+			t.Errorf("Unexpected step %d: skipped=%s level=%d", i, a.Skipped, a.Level)
+		}
+		if test.stdout != "" && (len(a.Results) != 1 || a.Results[0].Stdout != test.stdout) {
+			t.Errorf("Unexpected results in step %d: %+v", i, a.Results)
+		}
+	}
+
+	single := template.KV{
+		"cr_local_cmd":              "echo soft",
+		"cr_escalation_1_after":     "10m",
+		"cr_escalation_1_local_cmd": "echo hard",
+	}
+	for _, expected := range []string{"", "", "escalation_complete"} {
+		a := newAlert("escalation-complete", 0, single)
+		a.Alert.StartsAt = startsAt
+		_ = a.HandleAlert(context.Background(), c, logger)
+		if a.Skipped != expected {
+			t.Errorf("Unexpected skipped, expected %s got %s", expected, a.Skipped)
+		}
+	}
+
+	invalid := []template.KV{
+		{"cr_min_firing_duration": "foo"},
+		{"cr_escalation_1_after": "foo"},
+		{"cr_min_firing_duration": "10m", "cr_escalation_1_after": "5m"},
+		{"cr_escalation_1_after": "10m", "cr_escalation_2_after": "10m"},
+		{"cr_escalation_1_after": "10m", "cr_escalation_1_ssh_cmd": "{{ .Labels.dne }}"},
+		{"cr_escalation_1_after": "10m", "cr_escalation_1_local_cmd": "echo hard", "cr_escalation_2_after": "1h"},
+	}
+	for _, annotations := range invalid {
+		a := newAlert("escalation-invalid", time.Hour, annotations)
+		if err := a.HandleAlert(context.Background(), c, logger); err == nil {
+			t.Errorf("Expected an error for %v", annotations)
+		}
+	}
+
+	a = newAlert("escalation-no-command", time.Hour, template.KV{"cr_local_cmd": "echo soft", "cr_escalation_1_after": "10m"})
+	err := a.HandleAlert(context.Background(), c, logger)
+	if err == nil || err.Error() != "Escalation 1 requires cr_escalation_1_ssh_cmd or cr_escalation_1_local_cmd" {
+		t.Errorf("Unexpected error for escalation without a command: %v", err)
+	}
+	if len(a.Results) != 0 {
+		t.Errorf("Unexpected results: %+v", a.Results)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// templateData is the data available to templates in command annotations.
+type templateData struct {
+	template.Alert
+	Source
+	Alerts template.Alerts
+}
+
+// templateFuncs are the functions available to templates in command annotations.
+var templateFuncs = texttemplate.FuncMap{
+	"shellquote": shellQuote,
+}
+
+// shellQuote quotes the value as a single shell word so label values can not inject shell commands.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// mode returns alert when the responder runs for each alert or group when it runs once per group.
+func (a *Alert) mode() string {
+	if val, ok := a.Alert.Annotations[modeAnnotation]; ok {
+		return val
+	}
+	return "alert"
+}
+
+// render expands a command annotation as a Go template with the alert and the notification group it was received in.
+func (a *Alert) render(name string, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := texttemplate.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	alerts := a.Alerts
+	if len(alerts) == 0 {
+		alerts = template.Alerts{a.Alert}
+	}
+	var buffer strings.Builder
+	if err := tmpl.Execute(&buffer, templateData{Alert: a.Alert, Source: a.Source, Alerts: alerts}); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// GroupAlerts returns the alerts to handle for a notification.
+// The alerts of each responder using group mode are replaced by a single alert per status with the labels
+// and annotations common to all of them so the responder runs once per group rather than once per alert.
+// Firing and resolved alerts are in separate groups so resolved alerts still run their cleanup commands,
+// the firing episodes of group alerts are tracked for each of the combined alerts.
+// The combined alerts are kept in Alerts so commands can range over them.
+func GroupAlerts(alerts []Alert) []Alert {
+	var result []Alert
+	var keys []string
+	groups := make(map[string][]Alert)
+	for _, a := range alerts {
+		if a.mode() != "group" {
+			result = append(result, a)
+			continue
+		}
+		key := a.Name() + "/" + a.Status
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], a)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, newGroupAlert(groups[key]))
+	}
+	return result
+}
+
+// newGroupAlert builds the alert of a group of alerts with the same status.
+func newGroupAlert(alerts []Alert) Alert {
+	labels := commonKV(alerts, func(a Alert) template.KV { return a.Alert.Labels })
+	annotations := commonKV(alerts, func(a Alert) template.KV { return a.Alert.Annotations })
+	startsAt := alerts[0].StartsAt
+	members := make(template.Alerts, len(alerts))
+	for i, a := range alerts {
+		members[i] = a.Alert
+		if a.StartsAt.Before(startsAt) {
+			startsAt = a.StartsAt
+		}
+	}
+	return Alert{
+		Alert: template.Alert{
+			Status:      alerts[0].Status,
+			Labels:      labels,
+			Annotations: annotations,
+			StartsAt:    startsAt,
+			Fingerprint: fingerprint(labels),
+		},
+		Source:   alerts[0].Source,
+		DryRun:   alerts[0].DryRun,
+		Simulate: alerts[0].Simulate,
+		Alerts:   members,
+	}
+}
+
+// commonKV returns the pairs with the same value in every alert.
+func commonKV(alerts []Alert, kv func(Alert) template.KV) template.KV {
+	common := template.KV{}
+	for k, v := range kv(alerts[0]) {
+		common[k] = v
+	}
+	for _, a := range alerts[1:] {
+		pairs := kv(a)
+		for k, v := range common {
+			if val, ok := pairs[k]; !ok || val != v {
+				delete(common, k)
+			}
+		}
+	}
+	return common
+}
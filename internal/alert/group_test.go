@@ -0,0 +1,183 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func TestRender(t *testing.T) {
+	alert := &Alert{
+		Alert: template.Alert{
+			Labels: template.KV{"alertname": "foo", "host": "node1", "cmd": "$(reboot); echo 'x'"},
+		},
+		Source: Source{
+			Receiver:     "responder",
+			GroupLabels:  template.KV{"cluster": "c1"},
+			CommonLabels: template.KV{"alertname": "foo", "cluster": "c1"},
+			ExternalURL:  "http://alertmanager",
+		},
+	}
+	tests := map[string]string{
+		"restart":                                "restart",
+		"restart {{ .Labels.host }}":             "restart node1",
+		"drain {{ .GroupLabels.cluster }}":       "drain c1",
+		"{{ .CommonLabels.alertname }}":          "foo",
+		"{{ .Receiver }} {{ .ExternalURL }}":     "responder http://alertmanager",
+		"restart {{ shellquote .Labels.host }}":  "restart 'node1'",
+		"restart {{ .Labels.cmd | shellquote }}": `restart '$(reboot); echo '\''x'\'''`,
+	}
+	for text, expected := range tests {
+		out, err := alert.render("test", text)
+		if err != nil {
+			t.Errorf("Unexpected error rendering %s: %s", text, err)
+			continue
+		}
+		if out != expected {
+			t.Errorf("Unexpected output for %s, got: %s", text, out)
+		}
+	}
+	if _, err := alert.render("test", "{{ .Labels.dne }}"); err == nil {
+		t.Errorf("Expected an error for missing label")
+	}
+	if _, err := alert.render("test", "{{ .Labels.host"); err == nil {
+		t.Errorf("Expected an error for invalid template")
+	}
+}
+
+func TestGroupAlerts(t *testing.T) {
+	now := time.Now()
+	source := Source{Receiver: "responder", GroupKey: "{}:{cluster=\"c1\"}"}
+	alerts := []Alert{
+		{
+			Alert: template.Alert{
+				Status:      "firing",
+				Labels:      template.KV{"alertname": "drain", "cluster": "c1", "host": "node1"},
+				Annotations: template.KV{"cr_mode": "group", "cr_local_cmd": "drain", "summary": "node1 down"},
+				StartsAt:    now,
+				Fingerprint: "1",
+			},
+			Source: source,
+		},
+		{
+			Alert: template.Alert{
+				Status:      "resolved",
+				Labels:      template.KV{"alertname": "drain", "cluster": "c1", "host": "node2"},
+				Annotations: template.KV{"cr_mode": "group", "cr_local_cmd": "drain", "summary": "node2 down"},
+				StartsAt:    now.Add(-time.Minute),
+				Fingerprint: "2",
+			},
+			Source: source,
+		},
+		{
+			Alert: template.Alert{
+				Status:      "firing",
+				Labels:      template.KV{"alertname": "drain", "cluster": "c1", "host": "node3"},
+				Annotations: template.KV{"cr_mode": "group", "cr_local_cmd": "drain", "summary": "node3 down"},
+				StartsAt:    now.Add(-time.Minute),
+				Fingerprint: "4",
+			},
+			Source: source,
+		},
+		{
+			Alert: template.Alert{
+				Status:      "firing",
+				Labels:      template.KV{"alertname": "restart", "cluster": "c1", "host": "node1"},
+				Annotations: template.KV{"cr_local_cmd": "restart"},
+				Fingerprint: "3",
+			},
+			Source: source,
+		},
+	}
+	grouped := GroupAlerts(alerts)
+	if len(grouped) != 3 {
+		t.Fatalf("Unexpected number of alerts, got %d", len(grouped))
+	}
+	if grouped[0].Fingerprint != "3" {
+		t.Errorf("Unexpected alert, got %s", grouped[0].Fingerprint)
+	}
+	group := grouped[1]
+	if group.Status != "firing" {
+		t.Errorf("Unexpected status, got %s", group.Status)
+	}
+	if len(group.Labels) != 2 || group.Labels["alertname"] != "drain" || group.Labels["cluster"] != "c1" {
+		t.Errorf("Unexpected labels, got %v", group.Labels)
+	}
+	if _, ok := group.Annotations["summary"]; ok || group.Annotations["cr_local_cmd"] != "drain" {
+		t.Errorf("Unexpected annotations, got %v", group.Annotations)
+	}
+	if !group.StartsAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Unexpected starts at, got %v", group.StartsAt)
+	}
+	if group.Fingerprint != fingerprint(group.Labels) || group.Source.GroupKey != source.GroupKey {
+		t.Errorf("Unexpected group alert, got %+v", group)
+	}
+	if len(group.Alerts) != 2 || group.Alerts[0].Fingerprint != "1" || group.Alerts[1].Fingerprint != "4" {
+		t.Errorf("Unexpected group alerts, got %v", group.Alerts)
+	}
+	// Resolved alerts are a separate group
+	resolved := grouped[2]
+	if resolved.Status != "resolved" || len(resolved.Alerts) != 1 || resolved.Alerts[0].Fingerprint != "2" {
+		t.Errorf("Unexpected resolved group, got %+v", resolved)
+	}
+	out, err := group.render("test", "drain{{ range .Alerts }} {{ .Labels.host }}{{ end }}")
+	if err != nil {
+		t.Errorf("Unexpected error rendering: %s", err)
+	} else if out != "drain node1 node3" {
+		t.Errorf("Unexpected output, got: %s", out)
+	}
+	out, err = grouped[0].render("test", "{{ range .Alerts }}{{ .Labels.host }}{{ end }}")
+	if err != nil {
+		t.Errorf("Unexpected error rendering: %s", err)
+	} else if out != "node1" {
+		t.Errorf("Unexpected output, got: %s", out)
+	}
+}
+
+func TestHandleAlertReceivers(t *testing.T) {
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{LocalCommandTimeout: 2 * time.Second})
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	alert := &Alert{
+		Alert: template.Alert{
+			Status:      "firing",
+			Labels:      template.KV{"alertname": "foo"},
+			Annotations: template.KV{"cr_receivers": "responder", "cr_local_cmd": "echo {{ .Receiver }}"},
+			Fingerprint: "bar",
+		},
+		Source: Source{Receiver: "other"},
+	}
+	if err := alert.HandleAlert(context.Background(), sc.Config(), logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if alert.Skipped != "receiver" || len(alert.Results) != 0 {
+		t.Errorf("Expected alert to be skipped, got %+v", alert)
+	}
+	alert.Skipped = ""
+	alert.Source.Receiver = "responder"
+	if err := alert.HandleAlert(context.Background(), sc.Config(), logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(alert.Results) != 1 || alert.Results[0].Stdout != "responder\n" {
+		t.Errorf("Unexpected results, got %+v", alert.Results)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/cron"
+	"github.com/treydock/alertmanager-command-responder/internal/maintenance"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/pause"
+)
+
+// suppressReason returns why the commands of the alert must not run at the time, either the responder is paused,
+// a maintenance window matches the alert or the schedule of the responder does not allow it.
+func (a *Alert) suppressReason(now time.Time) string {
+	if pause.Paused(a.Name()) {
+		return "paused"
+	}
+	if w, ok := maintenance.Match(a.Alert.Labels, now); ok {
+		level.Info(a.logger).Log("msg", "Alert matches maintenance window", "window", w.ID, "comment", w.Comment)
+		return "maintenance"
+	}
+	if a.Response.Schedule == "" {
+		return ""
+	}
+	return scheduleReason(a.Response.schedule, now)
+}
+
+// scheduleReason returns blackout if the time is on a blackout date of the schedule
+// and schedule if it is outside the active windows.
+func scheduleReason(s config.Schedule, now time.Time) string {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		// Timezones are validated when the configuration is loaded
+		location = time.UTC
+	}
+	now = now.In(location)
+	date := now.Format(config.BlackoutDateFormat)
+	for _, b := range s.Blackout {
+		if b == date {
+			return "blackout"
+		}
+	}
+	if len(s.Active) == 0 {
+		return ""
+	}
+	for _, active := range s.Active {
+		e, err := cron.Parse(active)
+		if err == nil && e.Match(now) {
+			return ""
+		}
+	}
+	return "schedule"
+}
+
+// suppress records the commands that would have run as suppressed for the reason.
+func (a *Alert) suppress(reason string) {
+	metrics.SuppressedTotal.With(prometheus.Labels{"reason": reason}).Inc()
+	r := a.Response
+	if r.LocalCommand != "" {
+		result := CommandResult{Type: "local", Command: r.LocalCommand}
+		a.emit(result, reason, log.With(a.logger, "type", "local", "command", r.LocalCommand))
+	}
+	if r.SSHCommand != "" {
+		result := CommandResult{Type: "ssh", Command: r.SSHCommand, Host: r.SSHHost, Auth: r.authMethod()}
+		a.emit(result, reason, log.With(a.logger, "type", "ssh", "ssh_host", r.SSHHost, "command", r.SSHCommand))
+	}
+}
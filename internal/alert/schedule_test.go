@@ -0,0 +1,143 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/audit"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/maintenance"
+)
+
+func TestScheduleReason(t *testing.T) {
+	s := config.Schedule{
+		Name:     "business-hours",
+		Timezone: "America/New_York",
+		Active:   []string{"* 8-16 * * mon-fri"},
+		Blackout: []string{"2023-01-03"},
+	}
+	tests := []struct {
+		time     time.Time
+		expected string
+	}{
+		// Monday 09:00 in New York
+		{time.Date(2023, 1, 2, 14, 0, 0, 0, time.UTC), ""},
+		// Monday 07:00 in New York
+		{time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC), "schedule"},
+		// Tuesday 09:00 in New York is a blackout date
+		{time.Date(2023, 1, 3, 14, 0, 0, 0, time.UTC), "blackout"},
+		// Wednesday 01:00 UTC is still Tuesday in New York
+		{time.Date(2023, 1, 4, 1, 0, 0, 0, time.UTC), "blackout"},
+		// Saturday
+		{time.Date(2023, 1, 7, 14, 0, 0, 0, time.UTC), "schedule"},
+	}
+	for _, test := range tests {
+		if reason := scheduleReason(s, test.time); reason != test.expected {
+			t.Errorf("Unexpected reason at %s, expected %q got %q", test.time, test.expected, reason)
+		}
+	}
+	if reason := scheduleReason(config.Schedule{Blackout: []string{"2023-01-03"}}, time.Date(2023, 1, 4, 1, 0, 0, 0, time.UTC)); reason != "" {
+		t.Errorf("Unexpected reason for schedule without active windows: %s", reason)
+	}
+}
+
+func TestHandleAlertSuppressed(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	if err := audit.Configure(auditPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Configure("", nil)
+	c := &config.Config{
+		LocalCommandTimeout: 2 * time.Second,
+		Schedules: []config.Schedule{
+			{Name: "never", Active: []string{"* * 31 2 *"}},
+			{Name: "always"},
+		},
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	newAlert := func(labels template.KV, annotations template.KV) *Alert {
+		annotations["cr_local_cmd"] = "echo restart"
+		return &Alert{
+			Alert: template.Alert{
+				Status:      "firing",
+				Labels:      labels,
+				Annotations: annotations,
+				StartsAt:    time.Now(),
+				Fingerprint: "suppressed",
+			},
+		}
+	}
+
+	a := newAlert(template.KV{"alertname": "restart"}, template.KV{"cr_schedule": "never"})
+	if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Skipped != "schedule" || len(a.Results) != 0 {
+		t.Errorf("Unexpected alert: skipped=%s results=%+v", a.Skipped, a.Results)
+	}
+	a = newAlert(template.KV{"alertname": "restart"}, template.KV{"cr_schedule": "always"})
+	if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Skipped != "" || len(a.Results) != 1 {
+		t.Errorf("Unexpected alert: skipped=%s results=%+v", a.Skipped, a.Results)
+	}
+	a = newAlert(template.KV{"alertname": "restart"}, template.KV{"cr_schedule": "dne"})
+	if err := a.HandleAlert(context.Background(), c, logger); err == nil {
+		t.Errorf("Expected an error for unknown schedule")
+	}
+
+	if _, err := maintenance.Add(maintenance.Window{ID: "test", Matchers: []string{`instance=~"web.*"`}}); err != nil {
+		t.Fatal(err)
+	}
+	defer maintenance.Delete("test")
+	a = newAlert(template.KV{"alertname": "restart", "instance": "web1"}, template.KV{})
+	if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Skipped != "maintenance" || len(a.Results) != 0 {
+		t.Errorf("Unexpected alert: skipped=%s results=%+v", a.Skipped, a.Results)
+	}
+	a = newAlert(template.KV{"alertname": "restart", "instance": "db1"}, template.KV{})
+	if err := a.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Skipped != "" || len(a.Results) != 1 {
+		t.Errorf("Unexpected alert: skipped=%s results=%+v", a.Skipped, a.Results)
+	}
+
+	buffer, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(buffer)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Unexpected audit log: %s", buffer)
+	}
+	for i, reason := range []string{"schedule", "", "maintenance", ""} {
+		suppressed := strings.Contains(lines[i], `"outcome":"suppressed"`)
+		if suppressed != (reason != "") || (reason != "" && !strings.Contains(lines[i], `"reason":"`+reason+`"`)) {
+			t.Errorf("Unexpected audit entry %d: %s", i, lines[i])
+		}
+	}
+}
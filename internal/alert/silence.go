@@ -0,0 +1,107 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/treydock/alertmanager-command-responder/internal/alertmanager"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+// silence creates an Alertmanager silence for the alert so it does not fire again while the problem recovers.
+func (a *Alert) silence(c *config.Config, outcome string) {
+	r := a.Response
+	if r.SilenceDuration <= 0 || !utils.SliceContains(r.SilenceOn, outcome) {
+		return
+	}
+	names := r.SilenceMatchers
+	if len(names) == 0 {
+		for name := range a.Alert.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var matchers []alertmanager.Matcher
+	for _, name := range names {
+		value, ok := a.Alert.Labels[name]
+		if !ok {
+			level.Error(a.logger).Log("msg", "Silence matcher label not found on alert", "label", name)
+			metrics.ErrorsTotal.Inc()
+			return
+		}
+		matchers = append(matchers, alertmanager.Matcher{Name: name, Value: value, IsEqual: true})
+	}
+	var commands []string
+	for _, result := range a.Results {
+		command := fmt.Sprintf("%s '%s'", result.Type, result.Command)
+		if result.Host != "" {
+			command = fmt.Sprintf("%s on %s", command, result.Host)
+		}
+		commands = append(commands, command)
+	}
+	now := time.Now()
+	silence := alertmanager.Silence{
+		Matchers:  matchers,
+		StartsAt:  now,
+		EndsAt:    now.Add(r.SilenceDuration),
+		CreatedBy: alertmanager.CreatedBy,
+		Comment: fmt.Sprintf("Responder %s ran %s with outcome %s %s",
+			a.Name(), strings.Join(commands, ", "), outcome, silenceMarker(a.Alert.Fingerprint)),
+	}
+	id, err := alertmanager.NewClient(c.Alertmanager).CreateSilence(context.Background(), silence)
+	if err != nil {
+		level.Error(a.logger).Log("msg", "Unable to create silence", "err", err)
+		metrics.ErrorsTotal.Inc()
+		return
+	}
+	a.SilenceID = id
+	level.Info(a.logger).Log("msg", "Created silence", "silence", id, "duration", r.SilenceDuration)
+}
+
+// expireSilences expires the active silences created for the alert.
+func (a *Alert) expireSilences(c *config.Config) {
+	client := alertmanager.NewClient(c.Alertmanager)
+	silences, err := client.Silences(context.Background())
+	if err != nil {
+		level.Error(a.logger).Log("msg", "Unable to list silences", "err", err)
+		metrics.ErrorsTotal.Inc()
+		return
+	}
+	marker := silenceMarker(a.Alert.Fingerprint)
+	for _, silence := range silences {
+		if silence.CreatedBy != alertmanager.CreatedBy || silence.Status.State != "active" ||
+			!strings.HasSuffix(silence.Comment, marker) {
+			continue
+		}
+		if err := client.ExpireSilence(context.Background(), silence.ID); err != nil {
+			level.Error(a.logger).Log("msg", "Unable to expire silence", "silence", silence.ID, "err", err)
+			metrics.ErrorsTotal.Inc()
+			continue
+		}
+		level.Info(a.logger).Log("msg", "Expired silence", "silence", silence.ID)
+	}
+}
+
+// silenceMarker identifies the alert a silence was created for in the silence comment.
+func silenceMarker(fingerprint string) string {
+	return fmt.Sprintf("(fingerprint %s)", fingerprint)
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/alertmanager"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func TestSilence(t *testing.T) {
+	var lock sync.Mutex
+	var created []alertmanager.Silence
+	var expired []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/silences":
+			var silence alertmanager.Silence
+			if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+				t.Errorf("Unexpected error decoding silence: %s", err)
+			}
+			created = append(created, silence)
+			_ = json.NewEncoder(w).Encode(map[string]string{"silenceID": "silence1"})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v2/silences":
+			silences := []alertmanager.Silence{
+				{ID: "other", CreatedBy: "someone", Comment: silenceMarker("fp1"), Status: alertmanager.SilenceStatus{State: "active"}},
+				{ID: "expired", CreatedBy: alertmanager.CreatedBy, Comment: silenceMarker("fp1"), Status: alertmanager.SilenceStatus{State: "expired"}},
+			}
+			for _, silence := range created {
+				silence.ID = "silence1"
+				silence.Status.State = "active"
+				silences = append(silences, silence)
+			}
+			_ = json.NewEncoder(w).Encode(silences)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v2/silence/"):
+			expired = append(expired, strings.TrimPrefix(r.URL.Path, "/api/v2/silence/"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := &config.Config{
+		LocalCommandTimeout: time.Second,
+		Alertmanager:        config.AlertmanagerConfig{URL: server.URL, Timeout: time.Second},
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	annotations := map[string]string{
+		"cr_status":                    "firing",
+		"cr_local_cmd":                 "false",
+		"cr_silence_duration":          "15m",
+		"cr_silence_matchers":          "alertname,host",
+		"cr_silence_expire_on_resolve": "true",
+	}
+	alert := &Alert{
+		Alert: template.Alert{
+			Status:      "firing",
+			Labels:      map[string]string{"alertname": "foo", "host": "web01", "severity": "critical"},
+			Annotations: annotations,
+			Fingerprint: "fp1",
+		},
+	}
+	// Failed commands do not create a silence by default
+	_ = alert.HandleAlert(context.Background(), c, logger)
+	if len(created) != 0 {
+		t.Errorf("Unexpected silence created for failed command")
+	}
+
+	annotations["cr_local_cmd"] = "true"
+	alert.Results = nil
+	if err := alert.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(created) != 1 {
+		t.Fatalf("Expected 1 silence, got %d", len(created))
+	}
+	if alert.SilenceID != "silence1" {
+		t.Errorf("Unexpected silence ID %s", alert.SilenceID)
+	}
+	silence := created[0]
+	expectedMatchers := []alertmanager.Matcher{
+		{Name: "alertname", Value: "foo", IsEqual: true},
+		{Name: "host", Value: "web01", IsEqual: true},
+	}
+	if len(silence.Matchers) != 2 || silence.Matchers[0] != expectedMatchers[0] || silence.Matchers[1] != expectedMatchers[1] {
+		t.Errorf("Unexpected matchers: %v", silence.Matchers)
+	}
+	if silence.CreatedBy != alertmanager.CreatedBy {
+		t.Errorf("Unexpected createdBy: %s", silence.CreatedBy)
+	}
+	expectedComment := "Responder foo ran local 'true' with outcome success (fingerprint fp1)"
+	if silence.Comment != expectedComment {
+		t.Errorf("Unexpected comment\nExpected: %s\nGot: %s", expectedComment, silence.Comment)
+	}
+	if d := silence.EndsAt.Sub(silence.StartsAt); d != 15*time.Minute {
+		t.Errorf("Unexpected silence duration %s", d)
+	}
+
+	// A responder in dry run mode does not expire silences
+	dryRunAnnotations := map[string]string{"cr_dry_run": "true"}
+	for k, v := range annotations {
+		dryRunAnnotations[k] = v
+	}
+	dryRun := &Alert{
+		Alert: template.Alert{
+			Status:      "resolved",
+			Labels:      alert.Labels,
+			Annotations: dryRunAnnotations,
+			Fingerprint: "fp1",
+		},
+	}
+	if err := dryRun.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("Unexpected expired silences for dry run: %v", expired)
+	}
+
+	// Resolving the alert expires only the active silences created for it
+	resolved := &Alert{
+		Alert: template.Alert{
+			Status:      "resolved",
+			Labels:      alert.Labels,
+			Annotations: annotations,
+			Fingerprint: "fp1",
+		},
+	}
+	if err := resolved.HandleAlert(context.Background(), c, logger); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(expired) != 1 || expired[0] != "silence1" {
+		t.Errorf("Unexpected expired silences: %v", expired)
+	}
+}
+
+func TestSilenceRequiresAlertmanager(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	alert := &Alert{
+		Alert: template.Alert{
+			Labels:      map[string]string{"alertname": "foo"},
+			Annotations: map[string]string{"cr_silence_duration": "15m"},
+			Fingerprint: "bar",
+		},
+		logger: logger,
+	}
+	if _, err := alert.buildResponse(&config.Config{}); err == nil {
+		t.Errorf("Expected an error")
+	}
+	alert.Alert.Annotations = map[string]string{"cr_silence_duration": "foo"}
+	if _, err := alert.buildResponse(&config.Config{}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alertmanager is a minimal client for the Alertmanager API v2.
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// CreatedBy is used as the creator of silences so they can be found again.
+const CreatedBy = "alertmanager-command-responder"
+
+type Client struct {
+	config config.AlertmanagerConfig
+}
+
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+type Silence struct {
+	ID        string        `json:"id,omitempty"`
+	Matchers  []Matcher     `json:"matchers"`
+	StartsAt  time.Time     `json:"startsAt"`
+	EndsAt    time.Time     `json:"endsAt"`
+	CreatedBy string        `json:"createdBy"`
+	Comment   string        `json:"comment"`
+	Status    SilenceStatus `json:"status,omitempty"`
+}
+
+type SilenceStatus struct {
+	State string `json:"state"`
+}
+
+// Alert is an alert returned by the alerts endpoint.
+type Alert struct {
+	Fingerprint  string            `json:"fingerprint"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Receivers    []Receiver        `json:"receivers"`
+	Status       AlertStatus       `json:"status"`
+}
+
+type Receiver struct {
+	Name string `json:"name"`
+}
+
+type AlertStatus struct {
+	State       string   `json:"state"`
+	SilencedBy  []string `json:"silencedBy"`
+	InhibitedBy []string `json:"inhibitedBy"`
+}
+
+func NewClient(c config.AlertmanagerConfig) *Client {
+	return &Client{config: c}
+}
+
+// CreateSilence creates the silence and returns its ID.
+func (c *Client) CreateSilence(ctx context.Context, silence Silence) (string, error) {
+	var response struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := c.request(ctx, http.MethodPost, "/api/v2/silences", silence, &response); err != nil {
+		return "", err
+	}
+	return response.SilenceID, nil
+}
+
+// Silences returns all silences known to Alertmanager.
+func (c *Client) Silences(ctx context.Context) ([]Silence, error) {
+	var silences []Silence
+	err := c.request(ctx, http.MethodGet, "/api/v2/silences", nil, &silences)
+	return silences, err
+}
+
+// ExpireSilence expires the silence with the given ID.
+func (c *Client) ExpireSilence(ctx context.Context, id string) error {
+	return c.request(ctx, http.MethodDelete, "/api/v2/silence/"+id, nil, nil)
+}
+
+// Alerts returns the alerts matching the filter and receiver regex, including silenced and inhibited alerts.
+func (c *Client) Alerts(ctx context.Context, filter []string, receiver string) ([]Alert, error) {
+	query := url.Values{}
+	for _, f := range filter {
+		query.Add("filter", f)
+	}
+	if receiver != "" {
+		query.Set("receiver", receiver)
+	}
+	var alerts []Alert
+	err := c.request(ctx, http.MethodGet, "/api/v2/alerts?"+query.Encode(), nil, &alerts)
+	return alerts, err
+}
+
+func (c *Client) request(ctx context.Context, method string, path string, body interface{}, response interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		buffer, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewBuffer(buffer)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.config.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+string(c.config.Token))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Alertmanager request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Alertmanager request to %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("Unable to parse Alertmanager response: %v", err)
+	}
+	return nil
+}
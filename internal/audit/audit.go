@@ -0,0 +1,250 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit writes an append-only log of executed commands.
+// Each entry includes the hash of the previous entry so that modifying or removing
+// entries breaks the chain and is detected by Verify. The hashes are keyed with an HMAC
+// key when one is configured so the chain can not be rewritten without the key, and the
+// sequence and hash of the last entry are saved to a checkpoint file next to the log
+// so that removing entries from the end of the log is detected by VerifyFile.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+var (
+	logLock sync.Mutex
+	current *Log
+)
+
+// Entry records a single command execution.
+type Entry struct {
+	Seq         uint64            `json:"seq"`
+	Time        time.Time         `json:"time"`
+	RemoteAddr  string            `json:"remote_addr,omitempty"`
+	Receiver    string            `json:"receiver,omitempty"`
+	GroupKey    string            `json:"group_key,omitempty"`
+	Responder   string            `json:"responder"`
+	Fingerprint string            `json:"fingerprint"`
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Type        string            `json:"type"`
+	Command     string            `json:"command"`
+	Host        string            `json:"host,omitempty"`
+	User        string            `json:"user"`
+	Auth        string            `json:"auth,omitempty"`
+	Identity    string            `json:"identity,omitempty"`
+	Outcome     string            `json:"outcome"`
+	Error       string            `json:"error,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	PrevHash    string            `json:"prev_hash"`
+	Hash        string            `json:"hash,omitempty"`
+}
+
+// Log appends hash chained entries to a file.
+type Log struct {
+	path     string
+	key      []byte
+	file     *os.File
+	seq      uint64
+	lastHash string
+}
+
+// checkpoint is the sequence and hash of the last entry written to the log.
+type checkpoint struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// CheckpointPath returns the path of the checkpoint file of the audit log.
+func CheckpointPath(path string) string {
+	return path + ".checkpoint"
+}
+
+// Open opens the audit log for appending, continuing the chain of existing entries.
+// The key is used to compute the HMAC of entries, plain SHA256 hashes are used if it is empty.
+func Open(path string, key []byte) (*Log, error) {
+	l := &Log{path: path, key: key}
+	last, err := VerifyFile(path, key)
+	if err != nil {
+		return nil, fmt.Errorf("Existing audit log %s is invalid: %v", path, err)
+	}
+	l.seq = last.Seq
+	l.lastHash = last.Hash
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+// Write sets the sequence number and hashes of the entry and appends it to the log.
+func (l *Log) Write(e Entry) error {
+	e.Seq = l.seq + 1
+	e.Time = e.Time.UTC()
+	e.PrevHash = l.lastHash
+	hash, err := e.hash(l.key)
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.seq = e.Seq
+	l.lastHash = e.Hash
+	if err := utils.SaveFile(CheckpointPath(l.path), checkpoint{Seq: e.Seq, Hash: e.Hash}, json.Marshal); err != nil {
+		return fmt.Errorf("Unable to save audit log checkpoint: %v", err)
+	}
+	return nil
+}
+
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+func (e Entry) hash(key []byte) (string, error) {
+	e.Hash = ""
+	buffer, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	if len(key) == 0 {
+		sum := sha256.Sum256(buffer)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buffer)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify checks the chain of every entry and returns the last entry.
+func Verify(r io.Reader, key []byte) (Entry, error) {
+	return verify(r, key, checkpoint{})
+}
+
+// VerifyFile checks the chain of every entry of the audit log at path and that the log
+// still contains the entry of its checkpoint, a missing log is verified as empty.
+func VerifyFile(path string, key []byte) (Entry, error) {
+	var cp checkpoint
+	if err := utils.LoadFile(CheckpointPath(path), "audit log checkpoint", &cp, json.Unmarshal); err != nil {
+		return Entry{}, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		f, err = os.Open(os.DevNull)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+	last, err := verify(f, key, cp)
+	if err != nil {
+		return last, err
+	}
+	if last.Seq > 0 && !utils.FileExists(CheckpointPath(path)) {
+		return last, fmt.Errorf("checkpoint %s does not exist", CheckpointPath(path))
+	}
+	return last, nil
+}
+
+func verify(r io.Reader, key []byte, cp checkpoint) (Entry, error) {
+	var last Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return last, fmt.Errorf("line %d: unable to parse entry: %v", line, err)
+		}
+		if e.Seq != last.Seq+1 {
+			return last, fmt.Errorf("line %d: expected sequence %d, got %d", line, last.Seq+1, e.Seq)
+		}
+		if e.PrevHash != last.Hash {
+			return last, fmt.Errorf("line %d: previous hash does not match entry %d", line, last.Seq)
+		}
+		hash, err := e.hash(key)
+		if err != nil {
+			return last, fmt.Errorf("line %d: %v", line, err)
+		}
+		if hash != e.Hash {
+			return last, fmt.Errorf("line %d: hash does not match entry contents", line)
+		}
+		if e.Seq == cp.Seq && e.Hash != cp.Hash {
+			return last, fmt.Errorf("line %d: hash does not match checkpoint", line)
+		}
+		last = e
+	}
+	if err := scanner.Err(); err != nil {
+		return last, err
+	}
+	if last.Seq < cp.Seq {
+		return last, fmt.Errorf("log ends at entry %d but the checkpoint is entry %d", last.Seq, cp.Seq)
+	}
+	return last, nil
+}
+
+// Configure replaces the active audit log, auditing is disabled if path is empty.
+func Configure(path string, key []byte) error {
+	logLock.Lock()
+	defer logLock.Unlock()
+	if current != nil && current.path == path && bytes.Equal(current.key, key) {
+		return nil
+	}
+	var l *Log
+	if path != "" {
+		var err error
+		l, err = Open(path, key)
+		if err != nil {
+			return err
+		}
+	}
+	if current != nil {
+		_ = current.Close()
+	}
+	current = l
+	return nil
+}
+
+// Record writes the entry to the active audit log.
+func Record(e Entry) error {
+	logLock.Lock()
+	defer logLock.Unlock()
+	if current == nil {
+		return nil
+	}
+	return current.Write(e)
+}
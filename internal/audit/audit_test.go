@@ -0,0 +1,162 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeEntries(t *testing.T, path string, key []byte, commands ...string) {
+	l, err := Open(path, key)
+	if err != nil {
+		t.Fatalf("Unexpected error opening audit log: %s", err)
+	}
+	defer l.Close()
+	for _, command := range commands {
+		err := l.Write(Entry{
+			Time:        time.Now(),
+			RemoteAddr:  "127.0.0.1",
+			Receiver:    "command-responder",
+			Responder:   "restart-httpd",
+			Fingerprint: "abc",
+			Status:      "firing",
+			Labels:      map[string]string{"host": "web01"},
+			Type:        "ssh",
+			Command:     command,
+			Host:        "web01:22",
+			User:        "prometheus",
+			Outcome:     "success",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error writing audit log: %s", err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeEntries(t, path, nil, "first", "second")
+	// Reopening the log continues the existing chain
+	writeEntries(t, path, nil, "third")
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := Verify(bytes.NewReader(buffer), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error verifying audit log: %s", err)
+	}
+	if last.Seq != 3 || last.Command != "third" {
+		t.Errorf("Unexpected last entry: %v", last)
+	}
+
+	lines := strings.SplitAfter(string(buffer), "\n")
+	tampered := strings.Join([]string{lines[0], strings.Replace(lines[1], "second", "rm -rf", 1), lines[2]}, "")
+	if _, err := Verify(strings.NewReader(tampered), nil); err == nil || err.Error() != "line 2: hash does not match entry contents" {
+		t.Errorf("Unexpected error for modified entry: %v", err)
+	}
+	removed := strings.Join([]string{lines[0], lines[2]}, "")
+	if _, err := Verify(strings.NewReader(removed), nil); err == nil || err.Error() != "line 2: expected sequence 2, got 3" {
+		t.Errorf("Unexpected error for removed entry: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(tampered), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, nil); err == nil {
+		t.Errorf("Expected error opening tampered audit log")
+	}
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	buffer, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, buffer, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyRewrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	key := []byte("audit-key")
+	writeEntries(t, path, key, "first", "second")
+	if last, err := VerifyFile(path, key); err != nil || last.Seq != 2 {
+		t.Fatalf("Unexpected result verifying audit log: %v %v", last, err)
+	}
+	if _, err := VerifyFile(path, []byte("other-key")); err == nil || err.Error() != "line 1: hash does not match entry contents" {
+		t.Errorf("Unexpected error for wrong key: %v", err)
+	}
+
+	// Rewriting the whole log and checkpoint without the key breaks the chain
+	forged := filepath.Join(dir, "forged.log")
+	writeEntries(t, forged, nil, "first", "rm -rf")
+	copyFile(t, forged, path)
+	copyFile(t, CheckpointPath(forged), CheckpointPath(path))
+	if _, err := VerifyFile(path, key); err == nil || err.Error() != "line 1: hash does not match entry contents" {
+		t.Errorf("Unexpected error for rewritten log: %v", err)
+	}
+	if _, err := Open(path, key); err == nil {
+		t.Errorf("Expected error opening rewritten audit log")
+	}
+}
+
+func TestVerifyTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	key := []byte("audit-key")
+	writeEntries(t, path, key, "first", "second", "third")
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(buffer), "\n")
+
+	truncated := strings.Join(lines[:2], "")
+	if _, err := Verify(strings.NewReader(truncated), key); err != nil {
+		t.Errorf("Unexpected error verifying chain of truncated log: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(truncated), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(path, key); err == nil || err.Error() != "log ends at entry 2 but the checkpoint is entry 3" {
+		t.Errorf("Unexpected error for truncated log: %v", err)
+	}
+	if _, err := Open(path, key); err == nil {
+		t.Errorf("Expected error opening truncated audit log")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(path, key); err == nil || err.Error() != "log ends at entry 0 but the checkpoint is entry 3" {
+		t.Errorf("Unexpected error for removed log: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(truncated), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(CheckpointPath(path)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(path, key); err == nil || !strings.HasSuffix(err.Error(), "audit.log.checkpoint does not exist") {
+		t.Errorf("Unexpected error for removed checkpoint: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package breaker keeps a circuit breaker per responder that stops commands once they run on too many hosts
+// or too many targets are alerting. A tripped breaker stays tripped until it is reset.
+// The breakers are saved to a file when configured so they survive restarts.
+package breaker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+var (
+	lock       sync.Mutex
+	configured bool
+	path       string
+	breakers   = make(map[string]Breaker)
+)
+
+// Limits are the limits of a breaker within Window.
+// MaxHosts limits the number of distinct hosts commands run on and
+// MaxAlertingPercent limits the percent of Targets with firing alerts, a zero value disables a limit.
+type Limits struct {
+	Window             time.Duration `json:"window"`
+	MaxHosts           int           `json:"max_hosts"`
+	MaxAlertingPercent float64       `json:"max_alerting_percent"`
+	Targets            int           `json:"targets"`
+}
+
+// Enabled returns true if any limit is set.
+func (l Limits) Enabled() bool {
+	return l.MaxHosts > 0 || l.MaxAlertingPercent > 0
+}
+
+// Breaker is the state of the breaker of a responder.
+// Executions are the hosts commands ran on and Alerting holds when each firing alert was last seen.
+type Breaker struct {
+	Trip       *Trip                `json:"trip,omitempty"`
+	Executions []Execution          `json:"executions"`
+	Alerting   map[string]time.Time `json:"alerting"`
+}
+
+// Trip records when and why a breaker tripped.
+type Trip struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// Execution records commands running on a host.
+type Execution struct {
+	Time time.Time `json:"time"`
+	Host string    `json:"host"`
+}
+
+// Configure loads the breakers from the file, with an empty path the breakers are only kept in memory.
+func Configure(p string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if configured && p == path {
+		return nil
+	}
+	loaded := breakers
+	if p != "" {
+		var err error
+		loaded, err = load(p)
+		if err != nil {
+			return err
+		}
+	}
+	configured = true
+	path = p
+	breakers = loaded
+	updateMetrics()
+	return nil
+}
+
+// List returns a copy of all breakers.
+func List() map[string]Breaker {
+	lock.Lock()
+	defer lock.Unlock()
+	list := make(map[string]Breaker)
+	for name, b := range breakers {
+		list[name] = b
+	}
+	return list
+}
+
+// Tripped returns the trip of the breaker of the responder, nil if it has not tripped.
+func Tripped(responder string) *Trip {
+	lock.Lock()
+	defer lock.Unlock()
+	return breakers[responder].Trip
+}
+
+// Alerting records whether the alert with the fingerprint is firing for the responder.
+func Alerting(responder string, fingerprint string, firing bool, now time.Time) error {
+	lock.Lock()
+	defer lock.Unlock()
+	b := get(responder)
+	_, ok := b.Alerting[fingerprint]
+	if firing {
+		b.Alerting[fingerprint] = now
+	} else if ok {
+		delete(b.Alerting, fingerprint)
+	} else {
+		return nil
+	}
+	breakers[responder] = b
+	return save()
+}
+
+// Check returns the trip of the breaker of the responder if commands must not run on the host,
+// tripping the breaker if running them would exceed the limits. Otherwise the execution is recorded.
+// Tripped is true if the breaker tripped during this check.
+func Check(responder string, host string, l Limits, now time.Time) (trip *Trip, tripped bool, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+	b := get(responder)
+	if b.Trip != nil {
+		return b.Trip, false, nil
+	}
+	b.prune(now.Add(-l.Window))
+	if reason := b.exceeded(host, l); reason != "" {
+		b.Trip = &Trip{Time: now, Reason: reason}
+		tripped = true
+	} else {
+		b.Executions = append(b.Executions, Execution{Time: now, Host: host})
+	}
+	breakers[responder] = b
+	updateMetrics()
+	return b.Trip, tripped, save()
+}
+
+// Reset closes the breaker of the responder and forgets its executions, false is returned if it had not tripped.
+func Reset(responder string) (bool, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	b, ok := breakers[responder]
+	if !ok || b.Trip == nil {
+		return false, nil
+	}
+	b.Trip = nil
+	b.Executions = nil
+	breakers[responder] = b
+	updateMetrics()
+	return true, save()
+}
+
+func get(responder string) Breaker {
+	b := breakers[responder]
+	if b.Alerting == nil {
+		b.Alerting = make(map[string]time.Time)
+	}
+	return b
+}
+
+// prune removes executions and alerts not seen since the start of the window.
+func (b *Breaker) prune(start time.Time) {
+	var executions []Execution
+	for _, e := range b.Executions {
+		if e.Time.After(start) {
+			executions = append(executions, e)
+		}
+	}
+	b.Executions = executions
+	for fingerprint, seen := range b.Alerting {
+		if !seen.After(start) {
+			delete(b.Alerting, fingerprint)
+		}
+	}
+}
+
+// exceeded returns which limit running commands on the host would exceed.
+func (b *Breaker) exceeded(host string, l Limits) string {
+	if l.MaxHosts > 0 {
+		hosts := map[string]bool{host: true}
+		for _, e := range b.Executions {
+			hosts[e.Host] = true
+		}
+		if len(hosts) > l.MaxHosts {
+			return fmt.Sprintf("more than %d hosts in %s", l.MaxHosts, l.Window)
+		}
+	}
+	if l.MaxAlertingPercent > 0 && l.Targets > 0 {
+		percent := float64(len(b.Alerting)) * 100 / float64(l.Targets)
+		if percent > l.MaxAlertingPercent {
+			return fmt.Sprintf("%.0f%% of %d targets alerting, more than %g%%", percent, l.Targets, l.MaxAlertingPercent)
+		}
+	}
+	return ""
+}
+
+func updateMetrics() {
+	metrics.CircuitBreakerTripped.Reset()
+	for name, b := range breakers {
+		if b.Trip != nil {
+			metrics.CircuitBreakerTripped.WithLabelValues(name).Set(1)
+		} else {
+			metrics.CircuitBreakerTripped.WithLabelValues(name).Set(0)
+		}
+	}
+}
+
+func load(p string) (map[string]Breaker, error) {
+	loaded := make(map[string]Breaker)
+	if err := utils.LoadFile(p, "circuit breakers", &loaded, json.Unmarshal); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+func save() error {
+	return utils.SaveFile(path, breakers, json.Marshal)
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/testutils"
+)
+
+func TestMaxHosts(t *testing.T) {
+	p := testutils.ConfigureFile(t, "breakers.json", Configure)
+	l := Limits{Window: 10 * time.Minute, MaxHosts: 2}
+	now := time.Now()
+	tests := []struct {
+		host    string
+		time    time.Time
+		open    bool
+		tripped bool
+	}{
+		{host: "host1", time: now},
+		{host: "host1", time: now},
+		{host: "host2", time: now},
+		// Outside the window of the executions on host1
+		{host: "host3", time: now.Add(20 * time.Minute)},
+		{host: "host2", time: now.Add(21 * time.Minute)},
+		{host: "host4", time: now.Add(22 * time.Minute), open: true, tripped: true},
+		{host: "host3", time: now.Add(23 * time.Minute), open: true},
+	}
+	for i, test := range tests {
+		trip, tripped, err := Check("restart", test.host, l, test.time)
+		if err != nil {
+			t.Fatalf("Unexpected error in step %d: %s", i, err)
+		}
+		if (trip != nil) != test.open || tripped != test.tripped {
+			t.Errorf("Unexpected result in step %d: trip=%+v tripped=%v", i, trip, tripped)
+		}
+	}
+	if trip := Tripped("restart"); trip == nil || trip.Reason != "more than 2 hosts in 10m0s" {
+		t.Errorf("Unexpected trip: %+v", trip)
+	}
+	if val := testutil.ToFloat64(metrics.CircuitBreakerTripped.WithLabelValues("restart")); val != 1 {
+		t.Errorf("Unexpected tripped metric: %v", val)
+	}
+
+	// Breakers are loaded from the file
+	if err := Configure(""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	breakers = make(map[string]Breaker)
+	if err := Configure(p); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if Tripped("restart") == nil {
+		t.Errorf("Expected breaker to still be tripped")
+	}
+
+	if ok, err := Reset("restart"); !ok || err != nil {
+		t.Errorf("Unexpected reset result %v: %v", ok, err)
+	}
+	if ok, _ := Reset("restart"); ok {
+		t.Errorf("Expected reset of closed breaker to return false")
+	}
+	if trip, _, _ := Check("restart", "host4", l, now.Add(24*time.Minute)); trip != nil {
+		t.Errorf("Expected breaker to be closed after reset: %+v", trip)
+	}
+	if val := testutil.ToFloat64(metrics.CircuitBreakerTripped.WithLabelValues("restart")); val != 0 {
+		t.Errorf("Unexpected tripped metric: %v", val)
+	}
+}
+
+func TestMaxAlertingPercent(t *testing.T) {
+	defer func() {
+		breakers = make(map[string]Breaker)
+	}()
+	l := Limits{Window: 10 * time.Minute, MaxAlertingPercent: 50, Targets: 4}
+	now := time.Now()
+	for _, fingerprint := range []string{"fp1", "fp2", "fp3"} {
+		if err := Alerting("reboot", fingerprint, true, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Alerting("reboot", "fp3", false, now); err != nil {
+		t.Fatal(err)
+	}
+	if trip, _, _ := Check("reboot", "host1", l, now); trip != nil {
+		t.Errorf("Expected breaker to be closed: %+v", trip)
+	}
+	if err := Alerting("reboot", "fp4", true, now); err != nil {
+		t.Fatal(err)
+	}
+	trip, tripped, _ := Check("reboot", "host2", l, now)
+	if trip == nil || !tripped || trip.Reason != "75% of 4 targets alerting, more than 50%" {
+		t.Errorf("Unexpected trip: %+v", trip)
+	}
+	// Alerts not seen within the window are not counted
+	if _, err := Reset("reboot"); err != nil {
+		t.Fatal(err)
+	}
+	if trip, _, _ := Check("reboot", "host2", l, now.Add(time.Hour)); trip != nil {
+		t.Errorf("Expected breaker to be closed: %+v", trip)
+	}
+}
@@ -14,16 +14,21 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"os/user"
-	"sync"
+	"regexp"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/treydock/alertmanager-command-responder/internal/utils"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -31,25 +36,144 @@ const (
 	defaultSSHConnectionTimeout = "5s"
 	defaultSSHCommandTimeout    = "10s"
 	defaultLocalCommandTimeout  = "10s"
+	defaultProviderTimeout      = "10s"
+	defaultCertificateValidity  = "5m"
+	defaultAlertmanagerTimeout  = "10s"
+	defaultGrafanaTimeout       = "10s"
+	defaultGrafanaMaxTextSize   = 1024
+	defaultTracingTimeout       = "10s"
+	defaultStateMaxAge          = "168h"
 )
 
+var credentialProviderTypes = []string{"file", "env", "command", "vault", "ca"}
+
+var hostKeyAlgorithms = []string{
+	ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+	ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01, ssh.CertAlgoECDSA256v01,
+	ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01, ssh.CertAlgoED25519v01,
+	ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+	ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+	ssh.KeyAlgoED25519,
+}
+
+// SafeConfig holds the current configuration, which is replaced on reload.
+// Callers should get the configuration once with Config and use that snapshot for the
+// rest of the request so a reload does not change the configuration part way through.
 type SafeConfig struct {
 	path   string
 	logger log.Logger
-	mu     sync.Mutex
-	C      *Config
+	config atomic.Pointer[Config]
 }
 
 type Config struct {
-	SSHUser              string        `yaml:"ssh_user" json:"ssh_user"`
-	SSHKey               string        `yaml:"ssh_key" json:"ssh_key"`
-	SSHPassword          string        `yaml:"ssh_password" json:"ssh_password"`
-	SSHCertificate       string        `yaml:"ssh_certificate" json:"ssh_certificate"`
-	SSHKnownHosts        string        `yaml:"ssh_known_hosts" json:"ssh_known_hosts"`
-	SSHHostKeyAlgorithms []string      `yaml:"ssh_host_key_algorithms" json:"ssh_host_key_algorithms"`
-	SSHConnectionTimeout time.Duration `yaml:"ssh_connection_timeout" json:"ssh_connection_timeout"`
-	SSHCommandTimeout    time.Duration `yaml:"ssh_command_timeout" json:"ssh_command_timeout"`
-	LocalCommandTimeout  time.Duration `yaml:"local_command_timeout" json:"local_command_timeout"`
+	SSHUser               string               `yaml:"ssh_user" json:"ssh_user"`
+	SSHKey                string               `yaml:"ssh_key" json:"ssh_key"`
+	SSHPassword           Secret               `yaml:"ssh_password" json:"ssh_password"`
+	SSHPasswordFile       string               `yaml:"ssh_password_file" json:"ssh_password_file"`
+	SSHPasswordEnv        string               `yaml:"ssh_password_env" json:"ssh_password_env"`
+	SSHCertificate        string               `yaml:"ssh_certificate" json:"ssh_certificate"`
+	SSHKnownHosts         string               `yaml:"ssh_known_hosts" json:"ssh_known_hosts"`
+	SSHHostKeyAlgorithms  []string             `yaml:"ssh_host_key_algorithms" json:"ssh_host_key_algorithms"`
+	SSHConnectionTimeout  time.Duration        `yaml:"ssh_connection_timeout" json:"ssh_connection_timeout"`
+	SSHCommandTimeout     time.Duration        `yaml:"ssh_command_timeout" json:"ssh_command_timeout"`
+	LocalCommandTimeout   time.Duration        `yaml:"local_command_timeout" json:"local_command_timeout"`
+	APIToken              Secret               `yaml:"api_token" json:"api_token"`
+	APITokenFile          string               `yaml:"api_token_file" json:"api_token_file"`
+	APITokenEnv           string               `yaml:"api_token_env" json:"api_token_env"`
+	SSHCredentialProvider string               `yaml:"ssh_credential_provider" json:"ssh_credential_provider"`
+	CredentialProviders   []CredentialProvider `yaml:"credential_providers" json:"credential_providers"`
+	Notifiers             []Notifier           `yaml:"notifiers" json:"notifiers"`
+	DefaultNotifiers      []string             `yaml:"default_notifiers" json:"default_notifiers"`
+	NotifyOn              []string             `yaml:"notify_on" json:"notify_on"`
+	Alertmanager          AlertmanagerConfig   `yaml:"alertmanager" json:"alertmanager"`
+	Grafana               GrafanaConfig        `yaml:"grafana" json:"grafana"`
+	EventSinks            []EventSink          `yaml:"event_sinks" json:"event_sinks"`
+	Tracing               TracingConfig        `yaml:"tracing" json:"tracing"`
+	AuditLog              string               `yaml:"audit_log" json:"audit_log"`
+	AuditKey              Secret               `yaml:"audit_key" json:"audit_key"`
+	AuditKeyFile          string               `yaml:"audit_key_file" json:"audit_key_file"`
+	AuditKeyEnv           string               `yaml:"audit_key_env" json:"audit_key_env"`
+	Inputs                []Input              `yaml:"inputs" json:"inputs"`
+	StateFile             string               `yaml:"state_file" json:"state_file"`
+	StateMaxAge           time.Duration        `yaml:"state_max_age" json:"state_max_age"`
+	Schedules             []Schedule           `yaml:"schedules" json:"schedules"`
+	MaintenanceFile       string               `yaml:"maintenance_file" json:"maintenance_file"`
+	PauseFile             string               `yaml:"pause_file" json:"pause_file"`
+	BreakerFile           string               `yaml:"breaker_file" json:"breaker_file"`
+}
+
+type AlertmanagerConfig struct {
+	URL       string        `yaml:"url" json:"url"`
+	Token     Secret        `yaml:"token" json:"token"`
+	TokenFile string        `yaml:"token_file" json:"token_file"`
+	TokenEnv  string        `yaml:"token_env" json:"token_env"`
+	Timeout   time.Duration `yaml:"timeout" json:"timeout"`
+	Poll      PollConfig    `yaml:"poll" json:"poll"`
+}
+
+// PollConfig configures querying the Alertmanager API for alerts instead of receiving webhooks.
+type PollConfig struct {
+	Interval  time.Duration `yaml:"interval" json:"interval"`
+	Filter    []string      `yaml:"filter" json:"filter"`
+	Receiver  string        `yaml:"receiver" json:"receiver"`
+	StateFile string        `yaml:"state_file" json:"state_file"`
+}
+
+type TracingConfig struct {
+	Endpoint         string            `yaml:"endpoint" json:"endpoint"`
+	Insecure         bool              `yaml:"insecure" json:"insecure"`
+	Headers          map[string]Secret `yaml:"headers" json:"headers"`
+	Timeout          time.Duration     `yaml:"timeout" json:"timeout"`
+	SamplingFraction float64           `yaml:"sampling_fraction" json:"sampling_fraction"`
+}
+
+type GrafanaConfig struct {
+	URL          string        `yaml:"url" json:"url"`
+	Token        Secret        `yaml:"token" json:"token"`
+	TokenFile    string        `yaml:"token_file" json:"token_file"`
+	TokenEnv     string        `yaml:"token_env" json:"token_env"`
+	Timeout      time.Duration `yaml:"timeout" json:"timeout"`
+	DashboardUID string        `yaml:"dashboard_uid" json:"dashboard_uid"`
+	PanelID      int64         `yaml:"panel_id" json:"panel_id"`
+	Tags         []string      `yaml:"tags" json:"tags"`
+	MaxTextSize  int           `yaml:"max_text_size" json:"max_text_size"`
+}
+
+type CredentialProvider struct {
+	Name     string           `yaml:"name" json:"name"`
+	Type     string           `yaml:"type" json:"type"`
+	CacheTTL time.Duration    `yaml:"cache_ttl" json:"cache_ttl"`
+	Timeout  time.Duration    `yaml:"timeout" json:"timeout"`
+	Env      EnvCredentials   `yaml:"env" json:"env"`
+	Command  []string         `yaml:"command" json:"command"`
+	Vault    VaultCredentials `yaml:"vault" json:"vault"`
+	CA       CACredentials    `yaml:"ca" json:"ca"`
+}
+
+type EnvCredentials struct {
+	PrivateKey  string `yaml:"private_key" json:"private_key"`
+	Certificate string `yaml:"certificate" json:"certificate"`
+	Password    string `yaml:"password" json:"password"`
+}
+
+type CACredentials struct {
+	PrivateKey string        `yaml:"private_key" json:"private_key"`
+	URL        string        `yaml:"url" json:"url"`
+	Token      Secret        `yaml:"token" json:"token"`
+	TokenFile  string        `yaml:"token_file" json:"token_file"`
+	TokenEnv   string        `yaml:"token_env" json:"token_env"`
+	Principals []string      `yaml:"principals" json:"principals"`
+	Validity   time.Duration `yaml:"validity" json:"validity"`
+}
+
+type VaultCredentials struct {
+	Address   string `yaml:"address" json:"address"`
+	Token     Secret `yaml:"token" json:"token"`
+	TokenFile string `yaml:"token_file" json:"token_file"`
+	TokenEnv  string `yaml:"token_env" json:"token_env"`
+	KVPath    string `yaml:"kv_path" json:"kv_path"`
+	SignPath  string `yaml:"sign_path" json:"sign_path"`
 }
 
 func NewSafeConfig(path string, logger log.Logger) *SafeConfig {
@@ -59,46 +183,157 @@ func NewSafeConfig(path string, logger log.Logger) *SafeConfig {
 	}
 }
 
-func (sc *SafeConfig) ParseConfig() error {
+// Config returns the current configuration.
+func (sc *SafeConfig) Config() *Config {
+	return sc.config.Load()
+}
+
+// SetConfig replaces the current configuration.
+func (sc *SafeConfig) SetConfig(c *Config) {
+	sc.config.Store(c)
+}
+
+func (sc *SafeConfig) decodeConfig() (*Config, error) {
 	var c = &Config{}
 	yamlReader, err := os.Open(sc.path)
 	if err != nil {
 		level.Error(sc.logger).Log("msg", "Error reading config file", "path", sc.path, "err", err)
-		return err
+		return nil, err
 	}
 	defer yamlReader.Close()
 	decoder := yaml.NewDecoder(yamlReader)
 	decoder.KnownFields(true)
 	if err := decoder.Decode(c); err != nil {
 		level.Error(sc.logger).Log("msg", "Error parsing config file", "path", sc.path, "err", err)
-		return err
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseConfig parses the configuration file and loads its secrets without replacing the current configuration.
+func (sc *SafeConfig) parseConfig() (*Config, error) {
+	c, err := sc.decodeConfig()
+	if err != nil {
+		return nil, err
 	}
 	if c.SSHUser == "" {
 		u, err := user.Current()
 		if err != nil {
 			level.Error(sc.logger).Log("msg", "error getting current user", "err", err)
-			return err
+			return nil, err
 		}
 		c.SSHUser = u.Username
 	}
+	c.SSHPassword, err = loadSecret("ssh_password", c.SSHPassword, c.SSHPasswordFile, c.SSHPasswordEnv)
+	if err != nil {
+		level.Error(sc.logger).Log("msg", "Error loading SSH password", "err", err)
+		return nil, err
+	}
+	c.APIToken, err = loadSecret("api_token", c.APIToken, c.APITokenFile, c.APITokenEnv)
+	if err != nil {
+		level.Error(sc.logger).Log("msg", "Error loading API token", "err", err)
+		return nil, err
+	}
+	c.AuditKey, err = loadSecret("audit_key", c.AuditKey, c.AuditKeyFile, c.AuditKeyEnv)
+	if err != nil {
+		level.Error(sc.logger).Log("msg", "Error loading audit key", "err", err)
+		return nil, err
+	}
 	if c.SSHKey != "" {
 		if !utils.FileExists(c.SSHKey) {
 			level.Error(sc.logger).Log("msg", "SSH key does not exist", "sshkey", c.SSHKey)
-			return fmt.Errorf("SSH key does not exist: %s", c.SSHKey)
+			return nil, fmt.Errorf("SSH key does not exist: %s", c.SSHKey)
 		}
 	}
 	if c.SSHCertificate != "" {
 		if !utils.FileExists(c.SSHCertificate) {
 			level.Error(sc.logger).Log("msg", "SSH certificate does not exist", "ssh_certificate", c.SSHCertificate)
-			return fmt.Errorf("SSH certificate does not exist: %s", c.SSHCertificate)
+			return nil, fmt.Errorf("SSH certificate does not exist: %s", c.SSHCertificate)
 		}
 	}
 	if c.SSHKnownHosts != "" {
 		if !utils.FileExists(c.SSHKnownHosts) {
 			level.Error(sc.logger).Log("msg", "SSH known hosts does not exist", "path", c.SSHKnownHosts)
-			return fmt.Errorf("SSH known hosts does not exist: %s", c.SSHKnownHosts)
+			return nil, fmt.Errorf("SSH known hosts does not exist: %s", c.SSHKnownHosts)
 		}
 	}
+	for i := range c.CredentialProviders {
+		p := &c.CredentialProviders[i]
+		if p.Timeout == 0 {
+			p.Timeout, _ = time.ParseDuration(defaultProviderTimeout)
+		}
+		if p.CA.Validity == 0 {
+			p.CA.Validity, _ = time.ParseDuration(defaultCertificateValidity)
+		}
+		p.Vault.Token, err = loadSecret("token", p.Vault.Token, p.Vault.TokenFile, p.Vault.TokenEnv)
+		if err != nil {
+			level.Error(sc.logger).Log("msg", "Error loading Vault token", "provider", p.Name, "err", err)
+			return nil, err
+		}
+		p.CA.Token, err = loadSecret("token", p.CA.Token, p.CA.TokenFile, p.CA.TokenEnv)
+		if err != nil {
+			level.Error(sc.logger).Log("msg", "Error loading CA token", "provider", p.Name, "err", err)
+			return nil, err
+		}
+	}
+	if errs := c.validateCredentialProviders(); len(errs) > 0 {
+		level.Error(sc.logger).Log("msg", "Invalid credential providers", "err", errs[0])
+		return nil, errs[0]
+	}
+	if err := c.loadNotifiers(); err != nil {
+		level.Error(sc.logger).Log("msg", "Error loading notifiers", "err", err)
+		return nil, err
+	}
+	if errs := c.validateNotifiers(); len(errs) > 0 {
+		level.Error(sc.logger).Log("msg", "Invalid notifiers", "err", errs[0])
+		return nil, errs[0]
+	}
+	if len(c.NotifyOn) == 0 {
+		c.NotifyOn = NotifyEvents
+	}
+	c.loadEventSinks()
+	if c.Tracing.Timeout == 0 {
+		c.Tracing.Timeout, _ = time.ParseDuration(defaultTracingTimeout)
+	}
+	if c.Tracing.SamplingFraction == 0 {
+		c.Tracing.SamplingFraction = 1
+	}
+	if errs := c.validateEventSinks(); len(errs) > 0 {
+		level.Error(sc.logger).Log("msg", "Invalid event sinks", "err", errs[0])
+		return nil, errs[0]
+	}
+	c.loadInputs()
+	if errs := c.validateInputs(); len(errs) > 0 {
+		level.Error(sc.logger).Log("msg", "Invalid inputs", "err", errs[0])
+		return nil, errs[0]
+	}
+	if errs := c.validateSchedules(); len(errs) > 0 {
+		level.Error(sc.logger).Log("msg", "Invalid schedules", "err", errs[0])
+		return nil, errs[0]
+	}
+	c.Alertmanager.Token, err = loadSecret("token", c.Alertmanager.Token, c.Alertmanager.TokenFile, c.Alertmanager.TokenEnv)
+	if err != nil {
+		level.Error(sc.logger).Log("msg", "Error loading Alertmanager token", "err", err)
+		return nil, err
+	}
+	if c.Alertmanager.Timeout == 0 {
+		c.Alertmanager.Timeout, _ = time.ParseDuration(defaultAlertmanagerTimeout)
+	}
+	if errs := c.validatePoll(); len(errs) > 0 {
+		level.Error(sc.logger).Log("msg", "Invalid Alertmanager poll configuration", "err", errs[0])
+		return nil, errs[0]
+	}
+	c.Grafana.Token, err = loadSecret("token", c.Grafana.Token, c.Grafana.TokenFile, c.Grafana.TokenEnv)
+	if err != nil {
+		level.Error(sc.logger).Log("msg", "Error loading Grafana token", "err", err)
+		return nil, err
+	}
+	if c.Grafana.Timeout == 0 {
+		c.Grafana.Timeout, _ = time.ParseDuration(defaultGrafanaTimeout)
+	}
+	if c.Grafana.MaxTextSize == 0 {
+		c.Grafana.MaxTextSize = defaultGrafanaMaxTextSize
+	}
 	if c.SSHConnectionTimeout == 0 {
 		c.SSHConnectionTimeout, _ = time.ParseDuration(defaultSSHConnectionTimeout)
 	}
@@ -108,19 +343,224 @@ func (sc *SafeConfig) ParseConfig() error {
 	if c.LocalCommandTimeout == 0 {
 		c.LocalCommandTimeout, _ = time.ParseDuration(defaultLocalCommandTimeout)
 	}
+	if c.StateMaxAge == 0 {
+		c.StateMaxAge, _ = time.ParseDuration(defaultStateMaxAge)
+	}
+
+	return c, nil
+}
 
-	sc.mu.Lock()
-	sc.C = c
-	sc.mu.Unlock()
+func (sc *SafeConfig) ParseConfig() error {
+	c, err := sc.parseConfig()
+	if err != nil {
+		return err
+	}
+	sc.SetConfig(c)
 	return nil
 }
 
-func (sc *SafeConfig) ReadConfig() error {
+// LoadConfig reads and parses the configuration file without replacing the current configuration,
+// so components that depend on it can be configured before it is used.
+func (sc *SafeConfig) LoadConfig() (*Config, error) {
 	level.Info(sc.logger).Log("msg", "reading config", "path", sc.path)
-	if err := sc.ParseConfig(); err != nil {
-		return err
+	c, err := sc.parseConfig()
+	if err != nil {
+		return nil, err
 	}
-	cfgJson, _ := json.Marshal(sc.C)
+	cfgJson, _ := json.Marshal(c)
 	level.Debug(sc.logger).Log("msg", "parsed config", "config", cfgJson)
+	return c, nil
+}
+
+func (sc *SafeConfig) ReadConfig() error {
+	c, err := sc.LoadConfig()
+	if err != nil {
+		return err
+	}
+	sc.SetConfig(c)
+	return nil
+}
+
+// CheckConfig fully validates the configuration file and returns every error found.
+func (sc *SafeConfig) CheckConfig() []error {
+	c, err := sc.decodeConfig()
+	if err != nil {
+		return []error{err}
+	}
+	return c.Validate()
+}
+
+func (c *Config) Validate() []error {
+	var errs []error
+	if _, err := loadSecret("ssh_password", c.SSHPassword, c.SSHPasswordFile, c.SSHPasswordEnv); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := loadSecret("api_token", c.APIToken, c.APITokenFile, c.APITokenEnv); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := loadSecret("audit_key", c.AuditKey, c.AuditKeyFile, c.AuditKeyEnv); err != nil {
+		errs = append(errs, err)
+	}
+	var signer ssh.Signer
+	if c.SSHKey != "" {
+		key, err := os.ReadFile(c.SSHKey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Unable to read SSH key: %v", err))
+		} else if signer, err = ssh.ParsePrivateKey(key); err != nil {
+			errs = append(errs, fmt.Errorf("Unable to parse SSH key %s: %v", c.SSHKey, err))
+		}
+	}
+	if c.SSHCertificate != "" {
+		if c.SSHKey == "" {
+			errs = append(errs, fmt.Errorf("SSH certificate %s requires ssh_key", c.SSHCertificate))
+		}
+		if err := validateCertificate(c.SSHCertificate, signer); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.SSHKnownHosts != "" {
+		if _, err := knownhosts.New(c.SSHKnownHosts); err != nil {
+			errs = append(errs, fmt.Errorf("Unable to parse SSH known hosts %s: %v", c.SSHKnownHosts, err))
+		}
+	}
+	for _, algo := range c.SSHHostKeyAlgorithms {
+		if !utils.SliceContains(hostKeyAlgorithms, algo) {
+			errs = append(errs, fmt.Errorf("Unsupported SSH host key algorithm: %s", algo))
+		}
+	}
+	errs = append(errs, c.validateCredentialProviders()...)
+	for _, p := range c.CredentialProviders {
+		if _, err := loadSecret("token", p.Vault.Token, p.Vault.TokenFile, p.Vault.TokenEnv); err != nil {
+			errs = append(errs, fmt.Errorf("Credential provider %s: %v", p.Name, err))
+		}
+		if _, err := loadSecret("token", p.CA.Token, p.CA.TokenFile, p.CA.TokenEnv); err != nil {
+			errs = append(errs, fmt.Errorf("Credential provider %s: %v", p.Name, err))
+		}
+		if p.Type == "ca" && p.CA.PrivateKey != "" {
+			key, err := os.ReadFile(p.CA.PrivateKey)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Credential provider %s: Unable to read CA private key: %v", p.Name, err))
+			} else if _, err := ssh.ParsePrivateKey(key); err != nil {
+				errs = append(errs, fmt.Errorf("Credential provider %s: Unable to parse CA private key: %v", p.Name, err))
+			}
+		}
+		if p.CA.Validity < 0 {
+			errs = append(errs, fmt.Errorf("Credential provider %s: CA validity must not be negative", p.Name))
+		}
+	}
+	errs = append(errs, c.validateNotifiers()...)
+	errs = append(errs, c.validateEventSinks()...)
+	errs = append(errs, c.validateInputs()...)
+	errs = append(errs, c.validateSchedules()...)
+	for _, n := range c.Notifiers {
+		if _, err := loadSecret("url", n.URL, n.URLFile, n.URLEnv); err != nil {
+			errs = append(errs, fmt.Errorf("Notifier %s: %v", n.Name, err))
+		}
+		if _, err := loadSecret("password", n.Email.Password, n.Email.PasswordFile, n.Email.PasswordEnv); err != nil {
+			errs = append(errs, fmt.Errorf("Notifier %s: %v", n.Name, err))
+		}
+	}
+	if _, err := loadSecret("token", c.Alertmanager.Token, c.Alertmanager.TokenFile, c.Alertmanager.TokenEnv); err != nil {
+		errs = append(errs, fmt.Errorf("Alertmanager: %v", err))
+	}
+	if c.Alertmanager.URL != "" {
+		if _, err := url.Parse(c.Alertmanager.URL); err != nil {
+			errs = append(errs, fmt.Errorf("Unable to parse Alertmanager url: %v", err))
+		}
+	}
+	errs = append(errs, c.validatePoll()...)
+	if _, err := loadSecret("token", c.Grafana.Token, c.Grafana.TokenFile, c.Grafana.TokenEnv); err != nil {
+		errs = append(errs, fmt.Errorf("Grafana: %v", err))
+	}
+	if c.Grafana.URL != "" {
+		if _, err := url.Parse(c.Grafana.URL); err != nil {
+			errs = append(errs, fmt.Errorf("Unable to parse Grafana url: %v", err))
+		}
+	}
+	if c.Tracing.SamplingFraction < 0 || c.Tracing.SamplingFraction > 1 {
+		errs = append(errs, fmt.Errorf("Tracing sampling_fraction must be between 0 and 1: %v", c.Tracing.SamplingFraction))
+	}
+	durations := map[string]time.Duration{
+		"ssh_connection_timeout": c.SSHConnectionTimeout,
+		"ssh_command_timeout":    c.SSHCommandTimeout,
+		"local_command_timeout":  c.LocalCommandTimeout,
+	}
+	for _, name := range []string{"ssh_connection_timeout", "ssh_command_timeout", "local_command_timeout"} {
+		if durations[name] < 0 {
+			errs = append(errs, fmt.Errorf("Duration %s must not be negative: %s", name, durations[name]))
+		}
+	}
+	return errs
+}
+
+func (c *Config) validatePoll() []error {
+	var errs []error
+	if c.Alertmanager.Poll.Interval < 0 {
+		errs = append(errs, fmt.Errorf("Alertmanager poll interval must not be negative: %s", c.Alertmanager.Poll.Interval))
+	}
+	if c.Alertmanager.Poll.Interval > 0 && c.Alertmanager.URL == "" {
+		errs = append(errs, fmt.Errorf("Alertmanager poll requires alertmanager url"))
+	}
+	if _, err := regexp.Compile(c.Alertmanager.Poll.Receiver); err != nil {
+		errs = append(errs, fmt.Errorf("Unable to parse Alertmanager poll receiver regex: %v", err))
+	}
+	return errs
+}
+
+// CredentialProvider returns the credential provider with the given name.
+func (c *Config) CredentialProvider(name string) (CredentialProvider, bool) {
+	for _, p := range c.CredentialProviders {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return CredentialProvider{}, false
+}
+
+func (c *Config) validateCredentialProviders() []error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, p := range c.CredentialProviders {
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("Credential provider name is required"))
+		} else if names[p.Name] {
+			errs = append(errs, fmt.Errorf("Duplicate credential provider: %s", p.Name))
+		}
+		names[p.Name] = true
+		if !utils.SliceContains(credentialProviderTypes, p.Type) {
+			errs = append(errs, fmt.Errorf("Credential provider %s has unsupported type: %s", p.Name, p.Type))
+		}
+		if p.Type == "command" && len(p.Command) == 0 {
+			errs = append(errs, fmt.Errorf("Credential provider %s requires command", p.Name))
+		}
+		if p.Type == "vault" && (p.Vault.Address == "" || (p.Vault.KVPath == "" && p.Vault.SignPath == "")) {
+			errs = append(errs, fmt.Errorf("Credential provider %s requires vault address and kv_path or sign_path", p.Name))
+		}
+		if p.Type == "ca" && (p.CA.PrivateKey == "") == (p.CA.URL == "") {
+			errs = append(errs, fmt.Errorf("Credential provider %s requires one of ca private_key or url", p.Name))
+		}
+	}
+	if c.SSHCredentialProvider != "" && !names[c.SSHCredentialProvider] {
+		errs = append(errs, fmt.Errorf("Unknown SSH credential provider: %s", c.SSHCredentialProvider))
+	}
+	return errs
+}
+
+func validateCertificate(certificate string, signer ssh.Signer) error {
+	buffer, err := os.ReadFile(certificate)
+	if err != nil {
+		return fmt.Errorf("Unable to read SSH certificate: %v", err)
+	}
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(buffer)
+	if err != nil {
+		return fmt.Errorf("Unable to parse SSH certificate %s: %v", certificate, err)
+	}
+	cert, ok := pk.(*ssh.Certificate)
+	if !ok {
+		return fmt.Errorf("SSH certificate %s is not a certificate", certificate)
+	}
+	if signer != nil && !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return fmt.Errorf("SSH certificate %s does not match SSH key", certificate)
+	}
 	return nil
 }
@@ -14,12 +14,16 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/user"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
+	yaml "gopkg.in/yaml.v3"
 )
 
 func TestReloadConfigDefaults(t *testing.T) {
@@ -31,18 +35,18 @@ func TestReloadConfigDefaults(t *testing.T) {
 		t.Errorf("Unexpected err: %s", err.Error())
 		return
 	}
-	if sc.C.SSHUser != "prometheus" {
+	if sc.Config().SSHUser != "prometheus" {
 		t.Errorf("User does not match prometheus")
 	}
 	duration1, _ := time.ParseDuration("5s")
 	duration2, _ := time.ParseDuration("10s")
-	if sc.C.SSHConnectionTimeout != duration1 {
+	if sc.Config().SSHConnectionTimeout != duration1 {
 		t.Errorf("SSHConnectionTimeout does not match default 5s")
 	}
-	if sc.C.SSHCommandTimeout != duration2 {
+	if sc.Config().SSHCommandTimeout != duration2 {
 		t.Errorf("SSHCommandTimeout does not match default 10s")
 	}
-	if sc.C.LocalCommandTimeout != duration2 {
+	if sc.Config().LocalCommandTimeout != duration2 {
 		t.Errorf("LocalCommandTimeout does not match default 10s")
 	}
 	sc = NewSafeConfig("testdata/config-empty.yaml", logger)
@@ -56,8 +60,8 @@ func TestReloadConfigDefaults(t *testing.T) {
 		t.Errorf("Unexpected err: %s", err.Error())
 		return
 	}
-	if sc.C.SSHUser != currentUser {
-		t.Errorf("User does not match current user. Execpted: %s\nGot: %s", currentUser, sc.C.SSHUser)
+	if sc.Config().SSHUser != currentUser {
+		t.Errorf("User does not match current user. Execpted: %s\nGot: %s", currentUser, sc.Config().SSHUser)
 	}
 }
 
@@ -84,6 +88,34 @@ func TestReloadConfigBadConfigs(t *testing.T) {
 			ConfigFile:    "testdata/invalid-known_hosts.yaml",
 			ExpectedError: "SSH known hosts does not exist: dne",
 		},
+		{
+			ConfigFile:    "testdata/invalid-secrets.yaml",
+			ExpectedError: "Only one of ssh_password, ssh_password_file or ssh_password_env may be set",
+		},
+		{
+			ConfigFile:    "testdata/secrets.yaml",
+			ExpectedError: "Environment variable TEST_API_TOKEN for api_token_env is not set",
+		},
+		{
+			ConfigFile:    "testdata/invalid-credential_provider.yaml",
+			ExpectedError: "Credential provider vault requires vault address and kv_path or sign_path",
+		},
+		{
+			ConfigFile:    "testdata/invalid-notifier.yaml",
+			ExpectedError: "Notifier slack has invalid template: template: slack:1: unclosed action",
+		},
+		{
+			ConfigFile:    "testdata/invalid-input.yaml",
+			ExpectedError: "Input app: Unclosed bracket in JSONPath: $.items[",
+		},
+		{
+			ConfigFile:    "testdata/invalid-input-notify_on.yaml",
+			ExpectedError: "Input app: Unsupported cr_notify_on event: fail",
+		},
+		{
+			ConfigFile:    "testdata/invalid-schedule.yaml",
+			ExpectedError: "Schedule business-hours: Invalid cron expression * 8-17 * * mon-fry: value fry out of range 0-7",
+		},
 		{
 			ConfigFile:    "testdata/unknown-field.yaml",
 			ExpectedError: "yaml: unmarshal errors:\n  line 5: field invalid_extra_field not found in type config.Config",
@@ -101,3 +133,72 @@ func TestReloadConfigBadConfigs(t *testing.T) {
 		}
 	}
 }
+
+func TestSecrets(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	t.Setenv("TEST_API_TOKEN", "secret-from-env")
+	sc := NewSafeConfig("testdata/secrets.yaml", logger)
+	err := sc.ReadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if sc.Config().SSHPassword != "secret-from-file" {
+		t.Errorf("Unexpected SSH password: %s", string(sc.Config().SSHPassword))
+	}
+	if sc.Config().APIToken != "secret-from-env" {
+		t.Errorf("Unexpected API token: %s", string(sc.Config().APIToken))
+	}
+	cfgJson, err := json.Marshal(sc.Config())
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(cfgJson, &decoded); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if decoded["ssh_password"] != "<secret>" || decoded["api_token"] != "<secret>" {
+		t.Errorf("Secrets not redacted in JSON: %s", cfgJson)
+	}
+	cfgYaml, err := yaml.Marshal(sc.Config())
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if strings.Contains(string(cfgYaml), "secret-from") || !strings.Contains(string(cfgYaml), "api_token: <secret>") {
+		t.Errorf("Secrets not redacted in YAML: %s", cfgYaml)
+	}
+	if s := fmt.Sprintf("%s", sc.Config().SSHPassword); s != "<secret>" {
+		t.Errorf("Secret not redacted when formatted: %s", s)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	sc := NewSafeConfig("testdata/config.yaml", logger)
+	errs := sc.CheckConfig()
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	sc = NewSafeConfig("testdata/unknown-field.yaml", logger)
+	errs = sc.CheckConfig()
+	if len(errs) != 1 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	sc = NewSafeConfig("testdata/invalid-check.yaml", logger)
+	errs = sc.CheckConfig()
+	expected := []string{
+		"SSH certificate ../../cmd/alertmanager-command-responder/fixtures/id_rsa_test1-cert.pub does not match SSH key",
+		"Unable to parse SSH known hosts testdata/known_hosts-invalid: knownhosts: testdata/known_hosts-invalid:1: knownhosts: missing host pattern",
+		"Unsupported SSH host key algorithm: foo",
+		"Duration ssh_command_timeout must not be negative: -1s",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Unexpected number of errors, expected %d got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("In case %v:\nExpected:\n%v\nGot:\n%v", i, expected[i], err.Error())
+		}
+	}
+}
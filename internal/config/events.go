@@ -0,0 +1,73 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+const defaultOTLPTimeout = "10s"
+
+var eventSinkTypes = []string{"file", "syslog", "otlp"}
+
+type EventSink struct {
+	Name   string          `yaml:"name" json:"name"`
+	Type   string          `yaml:"type" json:"type"`
+	Path   string          `yaml:"path" json:"path"`
+	Syslog SyslogEventSink `yaml:"syslog" json:"syslog"`
+	OTLP   OTLPEventSink   `yaml:"otlp" json:"otlp"`
+}
+
+type SyslogEventSink struct {
+	Network string `yaml:"network" json:"network"`
+	Address string `yaml:"address" json:"address"`
+	Tag     string `yaml:"tag" json:"tag"`
+}
+
+type OTLPEventSink struct {
+	Endpoint string            `yaml:"endpoint" json:"endpoint"`
+	Headers  map[string]Secret `yaml:"headers" json:"headers"`
+	Timeout  time.Duration     `yaml:"timeout" json:"timeout"`
+}
+
+func (c *Config) loadEventSinks() {
+	for i := range c.EventSinks {
+		s := &c.EventSinks[i]
+		if s.Name == "" {
+			s.Name = s.Type
+		}
+		if s.OTLP.Timeout == 0 {
+			s.OTLP.Timeout, _ = time.ParseDuration(defaultOTLPTimeout)
+		}
+	}
+}
+
+func (c *Config) validateEventSinks() []error {
+	var errs []error
+	for _, s := range c.EventSinks {
+		if !utils.SliceContains(eventSinkTypes, s.Type) {
+			errs = append(errs, fmt.Errorf("Event sink %s has unsupported type: %s", s.Name, s.Type))
+		}
+		if s.Type == "file" && s.Path == "" {
+			errs = append(errs, fmt.Errorf("Event sink %s requires path", s.Name))
+		}
+		if s.Type == "otlp" && s.OTLP.Endpoint == "" {
+			errs = append(errs, fmt.Errorf("Event sink %s requires otlp endpoint", s.Name))
+		}
+	}
+	return errs
+}
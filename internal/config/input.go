@@ -0,0 +1,118 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/treydock/alertmanager-command-responder/internal/jsonpath"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+var (
+	inputTypes = []string{"alertmanager", "grafana", "json"}
+	inputName  = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// Input is an additional endpoint that receives alerts from tools other than Alertmanager.
+type Input struct {
+	Name string    `yaml:"name" json:"name"`
+	Type string    `yaml:"type" json:"type"`
+	JSON JSONInput `yaml:"json" json:"json"`
+}
+
+// JSONInput maps a generic JSON payload to alerts using JSONPath expressions.
+// Alerts selects the alert or list of alerts in the payload, every other path is relative to an alert.
+type JSONInput struct {
+	Alerts            string            `yaml:"alerts" json:"alerts"`
+	Status            string            `yaml:"status" json:"status"`
+	StatusMap         map[string]string `yaml:"status_map" json:"status_map"`
+	Fingerprint       string            `yaml:"fingerprint" json:"fingerprint"`
+	StartsAt          string            `yaml:"starts_at" json:"starts_at"`
+	EndsAt            string            `yaml:"ends_at" json:"ends_at"`
+	GeneratorURL      string            `yaml:"generator_url" json:"generator_url"`
+	Labels            map[string]string `yaml:"labels" json:"labels"`
+	LabelsFrom        string            `yaml:"labels_from" json:"labels_from"`
+	Annotations       map[string]string `yaml:"annotations" json:"annotations"`
+	AnnotationsFrom   string            `yaml:"annotations_from" json:"annotations_from"`
+	StaticLabels      map[string]string `yaml:"static_labels" json:"static_labels"`
+	StaticAnnotations map[string]string `yaml:"static_annotations" json:"static_annotations"`
+}
+
+// Input returns the input with the given name.
+func (c *Config) Input(name string) (Input, bool) {
+	for _, i := range c.Inputs {
+		if i.Name == name {
+			return i, true
+		}
+	}
+	return Input{}, false
+}
+
+func (c *Config) loadInputs() {
+	for i := range c.Inputs {
+		in := &c.Inputs[i]
+		if in.Type == "json" && in.JSON.Alerts == "" {
+			in.JSON.Alerts = "$"
+		}
+	}
+}
+
+func (c *Config) validateInputs() []error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, in := range c.Inputs {
+		if !inputName.MatchString(in.Name) {
+			errs = append(errs, fmt.Errorf("Input name must only contain letters, numbers, _ and -: %q", in.Name))
+		} else if names[in.Name] {
+			errs = append(errs, fmt.Errorf("Duplicate input: %s", in.Name))
+		}
+		names[in.Name] = true
+		if !utils.SliceContains(inputTypes, in.Type) {
+			errs = append(errs, fmt.Errorf("Input %s has unsupported type: %s", in.Name, in.Type))
+		}
+		if in.Type != "json" {
+			continue
+		}
+		paths := []string{in.JSON.Alerts, in.JSON.Status, in.JSON.Fingerprint, in.JSON.StartsAt, in.JSON.EndsAt,
+			in.JSON.GeneratorURL, in.JSON.LabelsFrom, in.JSON.AnnotationsFrom}
+		for _, p := range in.JSON.Labels {
+			paths = append(paths, p)
+		}
+		for _, p := range in.JSON.Annotations {
+			paths = append(paths, p)
+		}
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			if _, err := jsonpath.Parse(p); err != nil {
+				errs = append(errs, fmt.Errorf("Input %s: %v", in.Name, err))
+			}
+		}
+		if val, ok := in.JSON.StaticAnnotations["cr_notify_on"]; ok {
+			if err := ValidateNotifyOn("cr_notify_on", strings.Split(val, ",")); err != nil {
+				errs = append(errs, fmt.Errorf("Input %s: %v", in.Name, err))
+			}
+		}
+		for from, to := range in.JSON.StatusMap {
+			if to != "firing" && to != "resolved" {
+				errs = append(errs, fmt.Errorf("Input %s maps status %s to %s, must be firing or resolved", in.Name, from, to))
+			}
+		}
+	}
+	return errs
+}
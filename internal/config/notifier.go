@@ -0,0 +1,134 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+const defaultNotifierTimeout = "10s"
+
+var notifierTypes = []string{"webhook", "slack", "email"}
+
+// NotifyEvents are the execution outcomes notifications can be sent for.
+var NotifyEvents = []string{"success", "failure", "timeout"}
+
+// ValidateNotifyOn returns an error for the first event in events that notifications can not be sent for,
+// name is the setting the events are from.
+func ValidateNotifyOn(name string, events []string) error {
+	for _, event := range events {
+		if !utils.SliceContains(NotifyEvents, event) {
+			return fmt.Errorf("Unsupported %s event: %s", name, event)
+		}
+	}
+	return nil
+}
+
+type Notifier struct {
+	Name     string        `yaml:"name" json:"name"`
+	Type     string        `yaml:"type" json:"type"`
+	URL      Secret        `yaml:"url" json:"url"`
+	URLFile  string        `yaml:"url_file" json:"url_file"`
+	URLEnv   string        `yaml:"url_env" json:"url_env"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+	Template string        `yaml:"template" json:"template"`
+	Email    EmailConfig   `yaml:"email" json:"email"`
+}
+
+type EmailConfig struct {
+	Smarthost    string   `yaml:"smarthost" json:"smarthost"`
+	From         string   `yaml:"from" json:"from"`
+	To           []string `yaml:"to" json:"to"`
+	Subject      string   `yaml:"subject" json:"subject"`
+	Username     string   `yaml:"username" json:"username"`
+	Password     Secret   `yaml:"password" json:"password"`
+	PasswordFile string   `yaml:"password_file" json:"password_file"`
+	PasswordEnv  string   `yaml:"password_env" json:"password_env"`
+}
+
+// Notifier returns the notifier with the given name.
+func (c *Config) Notifier(name string) (Notifier, bool) {
+	for _, n := range c.Notifiers {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return Notifier{}, false
+}
+
+func (c *Config) loadNotifiers() error {
+	var err error
+	for i := range c.Notifiers {
+		n := &c.Notifiers[i]
+		if n.Timeout == 0 {
+			n.Timeout, _ = time.ParseDuration(defaultNotifierTimeout)
+		}
+		n.URL, err = loadSecret("url", n.URL, n.URLFile, n.URLEnv)
+		if err != nil {
+			return fmt.Errorf("Notifier %s: %v", n.Name, err)
+		}
+		n.Email.Password, err = loadSecret("password", n.Email.Password, n.Email.PasswordFile, n.Email.PasswordEnv)
+		if err != nil {
+			return fmt.Errorf("Notifier %s: %v", n.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateNotifiers() []error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, n := range c.Notifiers {
+		if n.Name == "" {
+			errs = append(errs, fmt.Errorf("Notifier name is required"))
+		} else if names[n.Name] {
+			errs = append(errs, fmt.Errorf("Duplicate notifier: %s", n.Name))
+		}
+		names[n.Name] = true
+		if !utils.SliceContains(notifierTypes, n.Type) {
+			errs = append(errs, fmt.Errorf("Notifier %s has unsupported type: %s", n.Name, n.Type))
+		}
+		if n.Type == "email" {
+			if n.Email.Smarthost == "" || n.Email.From == "" || len(n.Email.To) == 0 {
+				errs = append(errs, fmt.Errorf("Notifier %s requires email smarthost, from and to", n.Name))
+			}
+		} else if n.URL == "" && n.URLFile == "" && n.URLEnv == "" {
+			errs = append(errs, fmt.Errorf("Notifier %s requires url", n.Name))
+		}
+		if _, err := template.New(n.Name).Parse(n.Template); err != nil {
+			errs = append(errs, fmt.Errorf("Notifier %s has invalid template: %v", n.Name, err))
+		}
+		if _, err := template.New(n.Name).Parse(n.Email.Subject); err != nil {
+			errs = append(errs, fmt.Errorf("Notifier %s has invalid email subject: %v", n.Name, err))
+		}
+		if n.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("Notifier %s timeout must not be negative", n.Name))
+		}
+	}
+	for _, name := range c.DefaultNotifiers {
+		if !names[name] {
+			errs = append(errs, fmt.Errorf("Unknown notifier: %s", name))
+		}
+	}
+	for _, event := range c.NotifyOn {
+		if !utils.SliceContains(NotifyEvents, event) {
+			errs = append(errs, fmt.Errorf("Unsupported notify_on event: %s", event))
+		}
+	}
+	return errs
+}
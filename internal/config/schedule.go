@@ -0,0 +1,71 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/cron"
+)
+
+// BlackoutDateFormat is the format of schedule blackout dates.
+const BlackoutDateFormat = "2006-01-02"
+
+// Schedule limits when responders referencing it may run commands.
+// Active is a list of cron expressions matching the minutes commands may run, commands may always run if empty.
+// Blackout is a list of dates commands never run on. Both are evaluated in Timezone, UTC if not set.
+type Schedule struct {
+	Name     string   `yaml:"name" json:"name"`
+	Timezone string   `yaml:"timezone" json:"timezone"`
+	Active   []string `yaml:"active" json:"active"`
+	Blackout []string `yaml:"blackout" json:"blackout"`
+}
+
+// Schedule returns the schedule with the given name.
+func (c *Config) Schedule(name string) (Schedule, bool) {
+	for _, s := range c.Schedules {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Schedule{}, false
+}
+
+func (c *Config) validateSchedules() []error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, s := range c.Schedules {
+		if s.Name == "" {
+			errs = append(errs, fmt.Errorf("Schedule name is required"))
+		} else if names[s.Name] {
+			errs = append(errs, fmt.Errorf("Duplicate schedule: %s", s.Name))
+		}
+		names[s.Name] = true
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("Schedule %s has invalid timezone: %v", s.Name, err))
+		}
+		for _, a := range s.Active {
+			if _, err := cron.Parse(a); err != nil {
+				errs = append(errs, fmt.Errorf("Schedule %s: %v", s.Name, err))
+			}
+		}
+		for _, b := range s.Blackout {
+			if _, err := time.Parse(BlackoutDateFormat, b); err != nil {
+				errs = append(errs, fmt.Errorf("Schedule %s has invalid blackout date: %s", s.Name, b))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const secretToken = "<secret>"
+
+// Secret is a string that is redacted when marshaled to JSON or YAML and when logged.
+type Secret string
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return json.Marshal("")
+	}
+	return json.Marshal(secretToken)
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return secretToken, nil
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secretToken
+}
+
+// loadSecret returns the secret set inline, read from a file or read from an environment variable.
+// Only one source may be set.
+func loadSecret(name string, inline Secret, file string, env string) (Secret, error) {
+	sources := 0
+	for _, source := range []string{string(inline), file, env} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("Only one of %s, %s_file or %s_env may be set", name, name, name)
+	}
+	if file != "" {
+		buffer, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read %s_file: %v", name, err)
+		}
+		return Secret(strings.TrimSpace(string(buffer))), nil
+	}
+	if env != "" {
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("Environment variable %s for %s_env is not set", env, name)
+		}
+		return Secret(val), nil
+	}
+	return inline, nil
+}
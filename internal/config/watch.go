@@ -0,0 +1,106 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log/level"
+)
+
+// How long to wait for more changes before reloading, editors often write files in several steps
+const watchDelay = time.Second
+
+// Files returns the files referenced by the configuration.
+func (c *Config) Files() []string {
+	var files []string
+	for _, f := range []string{c.SSHKey, c.SSHCertificate, c.SSHKnownHosts, c.SSHPasswordFile, c.APITokenFile, c.Alertmanager.TokenFile, c.Grafana.TokenFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	for _, p := range c.CredentialProviders {
+		for _, f := range []string{p.Vault.TokenFile, p.CA.PrivateKey, p.CA.TokenFile} {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+	}
+	for _, n := range c.Notifiers {
+		for _, f := range []string{n.URLFile, n.Email.PasswordFile} {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// Watch calls reload when the configuration file or any file it references changes until done is closed.
+// Directories are watched rather than files so that files replaced by editors or configuration management are detected.
+func (sc *SafeConfig) Watch(done <-chan struct{}, reload func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := sc.watchFiles(watcher)
+	go func() {
+		defer watcher.Close()
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !watched[filepath.Clean(event.Name)] {
+					continue
+				}
+				level.Debug(sc.logger).Log("msg", "Config file changed", "path", event.Name, "op", event.Op.String())
+				timer = time.After(watchDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				level.Error(sc.logger).Log("msg", "Error watching config files", "err", err)
+			case <-timer:
+				timer = nil
+				reload()
+				watched = sc.watchFiles(watcher)
+			}
+		}
+	}()
+	return nil
+}
+
+func (sc *SafeConfig) watchFiles(watcher *fsnotify.Watcher) map[string]bool {
+	files := []string{sc.path}
+	if c := sc.Config(); c != nil {
+		files = append(files, c.Files()...)
+	}
+	watched := make(map[string]bool)
+	for _, f := range files {
+		f = filepath.Clean(f)
+		watched[f] = true
+		dir := filepath.Dir(f)
+		if err := watcher.Add(dir); err != nil {
+			level.Error(sc.logger).Log("msg", "Unable to watch directory", "path", dir, "err", err)
+		}
+	}
+	return watched
+}
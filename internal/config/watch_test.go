@@ -0,0 +1,102 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestFiles(t *testing.T) {
+	c := &Config{
+		SSHKey:          "/etc/key",
+		SSHKnownHosts:   "/etc/known_hosts",
+		SSHPasswordFile: "/etc/password",
+		MaintenanceFile: "/var/lib/maintenance.yaml",
+		CredentialProviders: []CredentialProvider{
+			{Name: "ca", Type: "ca", CA: CACredentials{PrivateKey: "/etc/ca"}},
+		},
+	}
+	expected := []string{"/etc/key", "/etc/known_hosts", "/etc/password", "/etc/ca"}
+	if files := c.Files(); !reflect.DeepEqual(files, expected) {
+		t.Errorf("Unexpected files\nExpected: %v\nGot: %v", expected, files)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("ssh_user: test\nssh_password_file: "+passwordFile+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	sc := NewSafeConfig(path, logger)
+	if err := sc.ReadConfig(); err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+	reloads := make(chan struct{}, 10)
+	done := make(chan struct{})
+	defer close(done)
+	err := sc.Watch(done, func() {
+		_ = sc.ReadConfig()
+		reloads <- struct{}{}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if err := os.WriteFile(path, []byte("ssh_user: test2\nssh_password_file: "+passwordFile+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Config was not reloaded after config file change")
+	}
+	if sc.Config().SSHUser != "test2" {
+		t.Errorf("Unexpected ssh_user %s", sc.Config().SSHUser)
+	}
+
+	if err := os.WriteFile(passwordFile, []byte("bar"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Config was not reloaded after password file change")
+	}
+	if sc.Config().SSHPassword != "bar" {
+		t.Errorf("Password was not reloaded")
+	}
+
+	// Files in the same directory that are not referenced do not trigger a reload
+	if err := os.WriteFile(filepath.Join(dir, "other"), []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloads:
+		t.Errorf("Unexpected reload for unrelated file")
+	case <-time.After(2 * watchDelay):
+	}
+}
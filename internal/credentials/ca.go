@@ -0,0 +1,191 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+// caProvider generates an ephemeral key for every request and gets a short lived
+// certificate for it, either signed by a local CA private key or by an HTTP signing endpoint.
+type caProvider struct {
+	config config.CredentialProvider
+}
+
+type signRequest struct {
+	PublicKey    string   `json:"public_key"`
+	Principals   []string `json:"principals"`
+	ValidSeconds int64    `json:"valid_seconds"`
+	KeyID        string   `json:"key_id"`
+}
+
+type signResponse struct {
+	Certificate string `json:"certificate"`
+}
+
+func (p *caProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *caProvider) Credentials(ctx context.Context, req Request) (Credentials, error) {
+	creds := Credentials{}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return creds, fmt.Errorf("Unable to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return creds, fmt.Errorf("Unable to marshal key: %v", err)
+	}
+	creds.PrivateKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	publicKey, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return creds, fmt.Errorf("Unable to create public key: %v", err)
+	}
+	principals := p.config.CA.Principals
+	if len(principals) == 0 {
+		principals = []string{req.User}
+	}
+	keyID := fmt.Sprintf("alertmanager-command-responder %s@%s", req.User, req.Host)
+	if p.config.CA.PrivateKey != "" {
+		creds.Certificate, err = p.signLocal(publicKey, principals, keyID)
+	} else {
+		creds.Certificate, err = p.signHTTP(ctx, publicKey, principals, keyID)
+	}
+	if err != nil {
+		return creds, err
+	}
+	cert, err := checkCertificate(creds.Certificate, publicKey, principals, time.Now())
+	if err != nil {
+		return creds, fmt.Errorf("Invalid certificate from credential provider %s: %v", p.Name(), err)
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		creds.Expiration = time.Unix(int64(cert.ValidBefore), 0)
+	}
+	return creds, nil
+}
+
+func (p *caProvider) signLocal(publicKey ssh.PublicKey, principals []string, keyID string) ([]byte, error) {
+	key, err := os.ReadFile(p.config.CA.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read CA private key: '%s' %v", p.config.CA.PrivateKey, err)
+	}
+	ca, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse CA private key: '%s' %v", p.config.CA.PrivateKey, err)
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             publicKey,
+		KeyId:           keyID,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: principals,
+		// Allow for clock skew between this host and the SSH server
+		ValidAfter:  uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore: uint64(now.Add(p.config.CA.Validity).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{"permit-pty": ""},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		return nil, fmt.Errorf("Unable to sign certificate: %v", err)
+	}
+	return ssh.MarshalAuthorizedKey(cert), nil
+}
+
+func (p *caProvider) signHTTP(ctx context.Context, publicKey ssh.PublicKey, principals []string, keyID string) ([]byte, error) {
+	body, err := json.Marshal(signRequest{
+		PublicKey:    string(ssh.MarshalAuthorizedKey(publicKey)),
+		Principals:   principals,
+		ValidSeconds: int64(p.config.CA.Validity.Seconds()),
+		KeyID:        keyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.CA.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.config.CA.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+string(p.config.CA.Token))
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Signing request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Signing request to %s returned %d", p.config.CA.URL, resp.StatusCode)
+	}
+	var response signResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("Unable to parse signing response: %v", err)
+	}
+	return []byte(response.Certificate), nil
+}
+
+// checkCertificate verifies a signed certificate is a user certificate for the ephemeral key
+// that is valid now and for exactly the requested principals.
+func checkCertificate(certificate []byte, publicKey ssh.PublicKey, principals []string, now time.Time) (*ssh.Certificate, error) {
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(certificate)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse certificate: %v", err)
+	}
+	cert, ok := pk.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("Public key is not a certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("Certificate is not a user certificate")
+	}
+	if !bytes.Equal(cert.Key.Marshal(), publicKey.Marshal()) {
+		return nil, fmt.Errorf("Certificate does not match the requested key")
+	}
+	unix := uint64(now.Unix())
+	if unix < cert.ValidAfter {
+		return nil, fmt.Errorf("Certificate is not valid until %s", time.Unix(int64(cert.ValidAfter), 0))
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && unix >= cert.ValidBefore {
+		return nil, fmt.Errorf("Certificate expired at %s", time.Unix(int64(cert.ValidBefore), 0))
+	}
+	for _, principal := range principals {
+		if !utils.SliceContains(cert.ValidPrincipals, principal) {
+			return nil, fmt.Errorf("Certificate is not valid for principal %s", principal)
+		}
+	}
+	for _, principal := range cert.ValidPrincipals {
+		if !utils.SliceContains(principals, principal) {
+			return nil, fmt.Errorf("Certificate is valid for principal %s that was not requested", principal)
+		}
+	}
+	return cert, nil
+}
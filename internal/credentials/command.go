@@ -0,0 +1,71 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// commandOutput is the JSON printed by an external credentials command.
+type commandOutput struct {
+	PrivateKey  string    `json:"private_key"`
+	Certificate string    `json:"certificate"`
+	Password    string    `json:"password"`
+	Expiration  time.Time `json:"expiration"`
+}
+
+// commandProvider runs an external command that prints credentials as JSON,
+// similar to credential_process used by AWS.
+type commandProvider struct {
+	config config.CredentialProvider
+}
+
+func (p *commandProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *commandProvider) Credentials(ctx context.Context, req Request) (Credentials, error) {
+	var stdout, stderr bytes.Buffer
+	creds := Credentials{}
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.config.Command[0], p.config.Command[1:]...)
+	cmd.Env = append(os.Environ(), "CR_SSH_USER="+req.User, "CR_SSH_HOST="+req.Host)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return creds, fmt.Errorf("Credentials command timed out: %s", strings.Join(p.config.Command, " "))
+	} else if err != nil {
+		return creds, fmt.Errorf("Credentials command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var output commandOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return creds, fmt.Errorf("Unable to parse credentials command output: %v", err)
+	}
+	creds.PrivateKey = []byte(output.PrivateKey)
+	creds.Certificate = []byte(output.Certificate)
+	creds.Password = config.Secret(output.Password)
+	creds.Expiration = output.Expiration
+	return creds, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+var (
+	cacheLock sync.Mutex
+	cache     = make(map[string]cachedCredentials)
+)
+
+// Request describes the SSH connection credentials are needed for.
+type Request struct {
+	User        string
+	Host        string
+	Key         string
+	Certificate string
+	Password    config.Secret
+}
+
+// Credentials are the SSH credentials returned by a provider.
+// Expiration is optional and limits how long credentials are cached.
+type Credentials struct {
+	PrivateKey  []byte
+	Certificate []byte
+	Password    config.Secret
+	Expiration  time.Time
+}
+
+type Provider interface {
+	Name() string
+	Credentials(ctx context.Context, req Request) (Credentials, error)
+}
+
+type cachedCredentials struct {
+	credentials Credentials
+	expires     time.Time
+}
+
+// New returns the provider for the configuration, the file provider is returned for an empty configuration.
+func New(c config.CredentialProvider) (Provider, error) {
+	switch c.Type {
+	case "", "file":
+		return &fileProvider{name: c.Name}, nil
+	case "env":
+		return &envProvider{config: c}, nil
+	case "command":
+		return &commandProvider{config: c}, nil
+	case "vault":
+		return &vaultProvider{config: c}, nil
+	case "ca":
+		return &caProvider{config: c}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported credential provider type: %s", c.Type)
+	}
+}
+
+// Get returns credentials from the provider, using cached credentials if they have not expired.
+// Expired credentials are evicted from the cache when new credentials are cached.
+func Get(ctx context.Context, p Provider, ttl time.Duration, req Request) (Credentials, error) {
+	key := strings.Join([]string{p.Name(), req.User, req.Host, req.Key, req.Certificate}, "|")
+	now := time.Now()
+	if ttl > 0 {
+		cacheLock.Lock()
+		cached, ok := cache[key]
+		cacheLock.Unlock()
+		if ok && now.Before(cached.expires) {
+			return cached.credentials, nil
+		}
+	}
+	creds, err := p.Credentials(ctx, req)
+	if err != nil {
+		return creds, err
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		if !creds.Expiration.IsZero() && creds.Expiration.Before(expires) {
+			expires = creds.Expiration
+		}
+		cacheLock.Lock()
+		for k, cached := range cache {
+			if !now.Before(cached.expires) {
+				delete(cache, k)
+			}
+		}
+		cache[key] = cachedCredentials{credentials: creds, expires: expires}
+		cacheLock.Unlock()
+	}
+	return creds, nil
+}
+
+// Reset evicts all cached credentials so rotated credentials are read again, for example after a reload.
+func Reset() {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cache = make(map[string]cachedCredentials)
+}
+
+// fileProvider reads the SSH key and certificate from the paths in the request.
+type fileProvider struct {
+	name string
+}
+
+func (p *fileProvider) Name() string {
+	return p.name
+}
+
+func (p *fileProvider) Credentials(ctx context.Context, req Request) (Credentials, error) {
+	creds := Credentials{Password: req.Password}
+	var err error
+	if req.Key != "" {
+		creds.PrivateKey, err = os.ReadFile(req.Key)
+		if err != nil {
+			return creds, fmt.Errorf("Unable to read private key: '%s' %v", req.Key, err)
+		}
+	}
+	if req.Certificate != "" {
+		creds.Certificate, err = os.ReadFile(req.Certificate)
+		if err != nil {
+			return creds, fmt.Errorf("Unable to read certificate file: '%s' %v", req.Certificate, err)
+		}
+	}
+	return creds, nil
+}
+
+// envProvider reads the SSH key, certificate and password from environment variables.
+type envProvider struct {
+	config config.CredentialProvider
+}
+
+func (p *envProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *envProvider) Credentials(ctx context.Context, req Request) (Credentials, error) {
+	creds := Credentials{}
+	vars := []struct {
+		name  string
+		value func(string)
+	}{
+		{p.config.Env.PrivateKey, func(v string) { creds.PrivateKey = []byte(v) }},
+		{p.config.Env.Certificate, func(v string) { creds.Certificate = []byte(v) }},
+		{p.config.Env.Password, func(v string) { creds.Password = config.Secret(v) }},
+	}
+	for _, v := range vars {
+		if v.name == "" {
+			continue
+		}
+		val, ok := os.LookupEnv(v.name)
+		if !ok {
+			return creds, fmt.Errorf("Environment variable %s is not set", v.name)
+		}
+		v.value(val)
+	}
+	return creds, nil
+}
@@ -0,0 +1,416 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+var fixtureDir = "../../cmd/alertmanager-command-responder/fixtures"
+
+type countingProvider struct {
+	count int
+}
+
+func (p *countingProvider) Name() string {
+	return "counting"
+}
+
+func (p *countingProvider) Credentials(ctx context.Context, req Request) (Credentials, error) {
+	p.count++
+	return Credentials{Password: "test"}, nil
+}
+
+func TestFileProvider(t *testing.T) {
+	p, err := New(config.CredentialProvider{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	creds, err := p.Credentials(context.Background(), Request{
+		Key:         filepath.Join(fixtureDir, "id_rsa_test1"),
+		Certificate: filepath.Join(fixtureDir, "id_rsa_test1-cert.pub"),
+		Password:    "test",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(creds.PrivateKey) == 0 || len(creds.Certificate) == 0 || creds.Password != "test" {
+		t.Errorf("Unexpected credentials: %+v", creds)
+	}
+	_, err = p.Credentials(context.Background(), Request{Key: "dne"})
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("TEST_SSH_PASSWORD", "password")
+	p, err := New(config.CredentialProvider{Name: "env", Type: "env", Env: config.EnvCredentials{Password: "TEST_SSH_PASSWORD"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	creds, err := p.Credentials(context.Background(), Request{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if creds.Password != "password" {
+		t.Errorf("Unexpected password: %s", string(creds.Password))
+	}
+	p, _ = New(config.CredentialProvider{Name: "env", Type: "env", Env: config.EnvCredentials{PrivateKey: "TEST_DNE"}})
+	if _, err = p.Credentials(context.Background(), Request{}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	p, err := New(config.CredentialProvider{
+		Name:    "command",
+		Type:    "command",
+		Timeout: 2 * time.Second,
+		Command: []string{"sh", "-c", `echo "{\"password\": \"$CR_SSH_USER\", \"expiration\": \"2030-01-01T00:00:00Z\"}"`},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	creds, err := p.Credentials(context.Background(), Request{User: "test"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if creds.Password != "test" {
+		t.Errorf("Unexpected password: %s", string(creds.Password))
+	}
+	if creds.Expiration.Year() != 2030 {
+		t.Errorf("Unexpected expiration: %s", creds.Expiration)
+	}
+	p, _ = New(config.CredentialProvider{Name: "command", Type: "command", Timeout: 2 * time.Second, Command: []string{"sh", "-c", "exit 1"}})
+	if _, err = p.Credentials(context.Background(), Request{}); err == nil {
+		t.Errorf("Expected an error")
+	}
+	p, _ = New(config.CredentialProvider{Name: "command", Type: "command", Timeout: 2 * time.Second, Command: []string{"echo", "foo"}})
+	if _, err = p.Credentials(context.Background(), Request{}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	key, err := os.ReadFile(filepath.Join(fixtureDir, "id_rsa_test1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, err := os.ReadFile(filepath.Join(fixtureDir, "CA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.ParsePrivateKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/proxy":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html>Bad Gateway</html>"))
+		case "/v1/secret/data/ssh":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]string{"private_key": string(key)},
+				},
+			})
+		case "/v1/ssh-client-signer/sign/role":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(body["public_key"]))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cert := &ssh.Certificate{
+				Key:             pub,
+				CertType:        ssh.UserCert,
+				ValidPrincipals: []string{body["valid_principals"]},
+				ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+				ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+			}
+			if err := cert.SignCert(rand.Reader, ca); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"signed_key": string(ssh.MarshalAuthorizedKey(cert))},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer server.Close()
+
+	c := config.CredentialProvider{
+		Name:    "vault",
+		Type:    "vault",
+		Timeout: 2 * time.Second,
+		Vault: config.VaultCredentials{
+			Address:  server.URL,
+			Token:    "token",
+			KVPath:   "secret/data/ssh",
+			SignPath: "ssh-client-signer/sign/role",
+		},
+	}
+	p, err := New(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	creds, err := p.Credentials(context.Background(), Request{User: "test"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(creds.PrivateKey) != string(key) {
+		t.Errorf("Unexpected private key")
+	}
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(creds.Certificate)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing certificate: %s", err)
+	}
+	if cert, ok := pk.(*ssh.Certificate); !ok || cert.ValidPrincipals[0] != "test" {
+		t.Errorf("Unexpected certificate: %+v", pk)
+	}
+	if creds.Expiration.IsZero() || creds.Expiration.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Unexpected expiration: %s", creds.Expiration)
+	}
+
+	c.Vault.Token = "wrong"
+	p, _ = New(c)
+	_, err = p.Credentials(context.Background(), Request{User: "test"})
+	if err == nil || err.Error() != "Vault request to secret/data/ssh returned 403: permission denied" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	c.Vault.Token = "token"
+	c.Vault.KVPath = "secret/data/proxy"
+	p, _ = New(c)
+	_, err = p.Credentials(context.Background(), Request{User: "test"})
+	if err == nil || err.Error() != "Vault request to secret/data/proxy returned 502" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCAProvider(t *testing.T) {
+	c := config.CredentialProvider{
+		Name:    "ca",
+		Type:    "ca",
+		Timeout: 2 * time.Second,
+		CA: config.CACredentials{
+			PrivateKey: filepath.Join(fixtureDir, "CA"),
+			Validity:   5 * time.Minute,
+		},
+	}
+	p, err := New(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	checkCredentials := func(creds Credentials, principal string) {
+		signer, err := ssh.ParsePrivateKey(creds.PrivateKey)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing private key: %s", err)
+		}
+		pk, _, _, _, err := ssh.ParseAuthorizedKey(creds.Certificate)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing certificate: %s", err)
+		}
+		cert, ok := pk.(*ssh.Certificate)
+		if !ok {
+			t.Fatalf("Expected a certificate, got %T", pk)
+		}
+		if string(cert.Key.Marshal()) != string(signer.PublicKey().Marshal()) {
+			t.Errorf("Certificate does not match private key")
+		}
+		if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != principal {
+			t.Errorf("Unexpected principals: %v", cert.ValidPrincipals)
+		}
+		if validity := time.Until(time.Unix(int64(cert.ValidBefore), 0)); validity > 5*time.Minute {
+			t.Errorf("Unexpected validity: %s", validity)
+		}
+		if !creds.Expiration.Equal(time.Unix(int64(cert.ValidBefore), 0)) {
+			t.Errorf("Expiration %s does not match certificate %d", creds.Expiration, cert.ValidBefore)
+		}
+	}
+	first, err := p.Credentials(context.Background(), Request{User: "test", Host: "localhost"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	checkCredentials(first, "test")
+	second, err := p.Credentials(context.Background(), Request{User: "test", Host: "localhost"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(first.PrivateKey) == string(second.PrivateKey) {
+		t.Errorf("Expected a new key for every request")
+	}
+
+	caKey, err := os.ReadFile(filepath.Join(fixtureDir, "CA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.ParsePrivateKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req signRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		principals := req.Principals
+		validAfter := time.Now().Add(-time.Minute)
+		validBefore := time.Now().Add(time.Duration(req.ValidSeconds) * time.Second)
+		switch response {
+		case "empty":
+			_ = json.NewEncoder(w).Encode(signResponse{})
+			return
+		case "key":
+			_ = json.NewEncoder(w).Encode(signResponse{Certificate: string(ssh.MarshalAuthorizedKey(pub))})
+			return
+		case "other-key":
+			pub = ca.PublicKey()
+		case "principals":
+			principals = []string{"other"}
+		case "extra-principals":
+			principals = append(principals, "root")
+		case "expired":
+			validBefore = time.Now().Add(-time.Second)
+		case "not-yet-valid":
+			validAfter = time.Now().Add(time.Hour)
+		case "short":
+			validBefore = time.Now().Add(time.Minute)
+		}
+		cert := &ssh.Certificate{
+			Key:             pub,
+			KeyId:           req.KeyID,
+			CertType:        ssh.UserCert,
+			ValidPrincipals: principals,
+			ValidAfter:      uint64(validAfter.Unix()),
+			ValidBefore:     uint64(validBefore.Unix()),
+		}
+		if err := cert.SignCert(rand.Reader, ca); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(signResponse{Certificate: string(ssh.MarshalAuthorizedKey(cert))})
+	}))
+	defer server.Close()
+	c.CA = config.CACredentials{
+		URL:        server.URL,
+		Token:      "token",
+		Principals: []string{"admin"},
+		Validity:   5 * time.Minute,
+	}
+	p, _ = New(c)
+	creds, err := p.Credentials(context.Background(), Request{User: "test", Host: "localhost"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	checkCredentials(creds, "admin")
+	// The expiration comes from the certificate, not the requested validity
+	response = "short"
+	creds, err = p.Credentials(context.Background(), Request{User: "test", Host: "localhost"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	checkCredentials(creds, "admin")
+	if time.Until(creds.Expiration) > time.Minute {
+		t.Errorf("Unexpected expiration: %s", creds.Expiration)
+	}
+	// Responses that are not a currently valid certificate for the key and principals are rejected
+	for _, response = range []string{"empty", "key", "other-key", "principals", "extra-principals", "expired", "not-yet-valid"} {
+		if _, err = p.Credentials(context.Background(), Request{User: "test"}); err == nil {
+			t.Errorf("Expected an error for %s response", response)
+		}
+	}
+	response = ""
+	c.CA.Token = "wrong"
+	p, _ = New(c)
+	if _, err = p.Credentials(context.Background(), Request{User: "test"}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestGetCache(t *testing.T) {
+	p := &countingProvider{}
+	req := Request{User: "test", Host: "cache"}
+	for i := 0; i < 3; i++ {
+		if _, err := Get(context.Background(), p, time.Minute, req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if p.count != 1 {
+		t.Errorf("Expected credentials to be cached, got %d calls", p.count)
+	}
+	req.Host = "no-cache"
+	for i := 0; i < 3; i++ {
+		if _, err := Get(context.Background(), p, 0, req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if p.count != 4 {
+		t.Errorf("Expected credentials to not be cached, got %d calls", p.count)
+	}
+
+	// Expired credentials are evicted when other credentials are cached
+	cacheLock.Lock()
+	cache["expired"] = cachedCredentials{expires: time.Now().Add(-time.Second)}
+	cacheLock.Unlock()
+	req.Host = "evict"
+	if _, err := Get(context.Background(), p, time.Minute, req); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	cacheLock.Lock()
+	_, ok := cache["expired"]
+	cacheLock.Unlock()
+	if ok {
+		t.Errorf("Expected expired credentials to be evicted")
+	}
+
+	// Reset evicts everything so credentials are read again
+	Reset()
+	req.Host = "cache"
+	if _, err := Get(context.Background(), p, time.Minute, req); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if p.count != 6 {
+		t.Errorf("Expected credentials to be read again after reset, got %d calls", p.count)
+	}
+}
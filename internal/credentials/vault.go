@@ -0,0 +1,155 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// vaultProvider reads credentials from a Vault KV secret and optionally
+// signs the public key using the Vault SSH secrets engine.
+type vaultProvider struct {
+	config config.CredentialProvider
+}
+
+type vaultResponse struct {
+	Data          map[string]interface{} `json:"data"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Errors        []string               `json:"errors"`
+}
+
+func (p *vaultProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *vaultProvider) Credentials(ctx context.Context, req Request) (Credentials, error) {
+	creds := Credentials{}
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	if p.config.Vault.KVPath != "" {
+		resp, err := p.request(ctx, http.MethodGet, p.config.Vault.KVPath, nil)
+		if err != nil {
+			return creds, err
+		}
+		data := resp.Data
+		// KV version 2 nests the secret data
+		if nested, ok := data["data"].(map[string]interface{}); ok {
+			data = nested
+		}
+		creds.PrivateKey = []byte(stringValue(data, "private_key"))
+		creds.Certificate = []byte(stringValue(data, "certificate"))
+		creds.Password = config.Secret(stringValue(data, "password"))
+		if resp.LeaseDuration > 0 {
+			creds.Expiration = time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second)
+		}
+	}
+	if p.config.Vault.SignPath == "" {
+		return creds, nil
+	}
+	if len(creds.PrivateKey) == 0 {
+		if req.Key == "" {
+			return creds, fmt.Errorf("Vault SSH signing requires a private key")
+		}
+		key, err := os.ReadFile(req.Key)
+		if err != nil {
+			return creds, fmt.Errorf("Unable to read private key: '%s' %v", req.Key, err)
+		}
+		creds.PrivateKey = key
+	}
+	signer, err := ssh.ParsePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return creds, fmt.Errorf("Unable to parse private key: %v", err)
+	}
+	body := map[string]string{
+		"public_key": string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+	}
+	if req.User != "" {
+		body["valid_principals"] = req.User
+	}
+	resp, err := p.request(ctx, http.MethodPost, p.config.Vault.SignPath, body)
+	if err != nil {
+		return creds, err
+	}
+	creds.Certificate = []byte(stringValue(resp.Data, "signed_key"))
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(creds.Certificate)
+	if err != nil {
+		return creds, fmt.Errorf("Unable to parse signed key from Vault: %v", err)
+	}
+	if cert, ok := pk.(*ssh.Certificate); ok && cert.ValidBefore != ssh.CertTimeInfinity {
+		expiration := time.Unix(int64(cert.ValidBefore), 0)
+		if creds.Expiration.IsZero() || expiration.Before(creds.Expiration) {
+			creds.Expiration = expiration
+		}
+	}
+	return creds, nil
+}
+
+func (p *vaultProvider) request(ctx context.Context, method string, path string, body interface{}) (vaultResponse, error) {
+	var response vaultResponse
+	var reader io.Reader
+	if body != nil {
+		buffer, err := json.Marshal(body)
+		if err != nil {
+			return response, err
+		}
+		reader = bytes.NewBuffer(buffer)
+	}
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(p.config.Vault.Address, "/"), strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("X-Vault-Token", string(p.config.Vault.Token))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return response, fmt.Errorf("Vault request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Errors from proxies in front of Vault may not be JSON
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		if len(response.Errors) == 0 {
+			return response, fmt.Errorf("Vault request to %s returned %d", path, resp.StatusCode)
+		}
+		return response, fmt.Errorf("Vault request to %s returned %d: %s", path, resp.StatusCode, strings.Join(response.Errors, ", "))
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return response, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, fmt.Errorf("Unable to parse Vault response: %v", err)
+	}
+	return response, nil
+}
+
+func stringValue(data map[string]interface{}, key string) string {
+	if val, ok := data[key].(string); ok {
+		return val
+	}
+	return ""
+}
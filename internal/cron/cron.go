@@ -0,0 +1,134 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cron matches times against standard five field cron expressions.
+// Fields are minute, hour, day of month, month and day of week and support *, lists, ranges, steps
+// and the names sun to sat for days of the week.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type field struct {
+	min    int
+	max    int
+	names  []string
+	values map[int]bool
+	any    bool
+}
+
+// Expression is a parsed cron expression.
+type Expression struct {
+	minute  field
+	hour    field
+	day     field
+	month   field
+	weekday field
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (Expression, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Expression{}, fmt.Errorf("Cron expression must have 5 fields: %s", expr)
+	}
+	e := Expression{
+		minute:  field{min: 0, max: 59},
+		hour:    field{min: 0, max: 23},
+		day:     field{min: 1, max: 31},
+		month:   field{min: 1, max: 12},
+		weekday: field{min: 0, max: 7, names: weekdays},
+	}
+	for i, f := range []*field{&e.minute, &e.hour, &e.day, &e.month, &e.weekday} {
+		if err := f.parse(fields[i]); err != nil {
+			return Expression{}, fmt.Errorf("Invalid cron expression %s: %v", expr, err)
+		}
+	}
+	// 7 is also Sunday
+	if e.weekday.values[7] {
+		e.weekday.values[0] = true
+	}
+	return e, nil
+}
+
+// Match returns true if the minute of t matches the expression.
+// As with cron, when both day of month and day of week are restricted either may match.
+func (e Expression) Match(t time.Time) bool {
+	if !e.minute.match(t.Minute()) || !e.hour.match(t.Hour()) || !e.month.match(int(t.Month())) {
+		return false
+	}
+	if !e.day.any && !e.weekday.any {
+		return e.day.match(t.Day()) || e.weekday.match(int(t.Weekday()))
+	}
+	return e.day.match(t.Day()) && e.weekday.match(int(t.Weekday()))
+}
+
+func (f *field) match(value int) bool {
+	return f.any || f.values[value]
+}
+
+func (f *field) parse(expr string) error {
+	f.values = make(map[int]bool)
+	f.any = expr == "*"
+	for _, part := range strings.Split(expr, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %s", part[i+1:])
+			}
+			part = part[:i]
+		}
+		start, end := f.min, f.max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return err
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = f.value(bounds[1]); err != nil {
+					return err
+				}
+			}
+			if end < start {
+				return fmt.Errorf("invalid range %s", part)
+			}
+		}
+		for v := start; v <= end; v += step {
+			f.values[v] = true
+		}
+	}
+	return nil
+}
+
+func (f *field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %s out of range %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
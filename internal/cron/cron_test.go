@@ -0,0 +1,65 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	// Monday
+	monday := time.Date(2023, 1, 2, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		time     time.Time
+		expected bool
+	}{
+		{"* * * * *", monday, true},
+		{"30 9 * * *", monday, true},
+		{"0-29 9 * * *", monday, false},
+		{"*/15 * * * *", monday, true},
+		{"*/20 * * * *", monday, false},
+		{"* 8-17 * * mon-fri", monday, true},
+		{"* 8-17 * * sat,sun", monday, false},
+		{"* 8-17 * * 0,6", monday, false},
+		{"* * * * 7", monday.AddDate(0, 0, 6), true},
+		{"* * 2 1 *", monday, true},
+		{"* * * 2 *", monday, false},
+		// Either day of month or day of week
+		{"* * 15 * mon", monday, true},
+		{"* * 2 * sun", monday, true},
+		{"* * 15 * sun", monday, false},
+		{"* 0-7,18-23 * * *", monday, false},
+	}
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", test.expr, err)
+			continue
+		}
+		if e.Match(test.time) != test.expected {
+			t.Errorf("Unexpected match for %s at %s, expected %v", test.expr, test.time, test.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"* * * * foo", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected an error parsing %s", expr)
+		}
+	}
+}
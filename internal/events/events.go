@@ -0,0 +1,116 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events emits a structured event for every command execution to external sinks.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+)
+
+var (
+	sinksLock sync.Mutex
+	active    = &sinkSet{}
+)
+
+// sinkSet is the sinks of one configuration, the sinks are closed once the events being sent to them are done.
+type sinkSet struct {
+	sinks    []Sink
+	inflight sync.WaitGroup
+}
+
+// Event describes a single command execution.
+// Commands that were suppressed have the outcome suppressed and the reason they did not run.
+type Event struct {
+	Time        time.Time         `json:"time"`
+	Responder   string            `json:"responder"`
+	Fingerprint string            `json:"fingerprint"`
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Type        string            `json:"type"`
+	Command     string            `json:"command"`
+	Host        string            `json:"host,omitempty"`
+	Auth        string            `json:"auth,omitempty"`
+	Outcome     string            `json:"outcome"`
+	Error       string            `json:"error,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	TimedOut    bool              `json:"timed_out"`
+	Duration    float64           `json:"duration"`
+}
+
+type Sink interface {
+	Name() string
+	Emit(e Event) error
+	Close() error
+}
+
+// New returns the sink for the configuration.
+func New(c config.EventSink) (Sink, error) {
+	switch c.Type {
+	case "file":
+		return newFileSink(c)
+	case "syslog":
+		return newSyslogSink(c)
+	case "otlp":
+		return &otlpSink{config: c}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported event sink type: %s", c.Type)
+	}
+}
+
+// Configure replaces the active sinks with sinks built from the configuration.
+func Configure(configs []config.EventSink) error {
+	var newSinks []Sink
+	for _, c := range configs {
+		s, err := New(c)
+		if err != nil {
+			for _, s := range newSinks {
+				_ = s.Close()
+			}
+			return fmt.Errorf("Unable to create event sink %s: %v", c.Name, err)
+		}
+		newSinks = append(newSinks, s)
+	}
+	sinksLock.Lock()
+	old := active
+	active = &sinkSet{sinks: newSinks}
+	sinksLock.Unlock()
+	old.inflight.Wait()
+	for _, s := range old.sinks {
+		_ = s.Close()
+	}
+	return nil
+}
+
+// Emit sends the event to every active sink, errors are logged and counted.
+// The lock is not held while sending so a slow sink does not block other events or a reload.
+func Emit(e Event, logger log.Logger) {
+	sinksLock.Lock()
+	set := active
+	set.inflight.Add(1)
+	sinksLock.Unlock()
+	defer set.inflight.Done()
+	for _, s := range set.sinks {
+		if err := s.Emit(e); err != nil {
+			level.Error(logger).Log("msg", "Unable to emit event", "sink", s.Name(), "err", err)
+			metrics.EventErrorsTotal.WithLabelValues(s.Name()).Inc()
+		}
+	}
+}
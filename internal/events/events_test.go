@@ -0,0 +1,220 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func testEvent() Event {
+	return Event{
+		Time:        time.Unix(1700000000, 0),
+		Responder:   "restart-httpd",
+		Fingerprint: "abc",
+		Status:      "firing",
+		Labels:      map[string]string{"host": "web01"},
+		Type:        "ssh",
+		Command:     "systemctl restart httpd",
+		Host:        "web01:22",
+		Auth:        "key",
+		Outcome:     "failure",
+		Error:       "exit status 1",
+		Duration:    1.5,
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	if err := Configure([]config.EventSink{{Name: "file", Type: "file", Path: path}}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer Configure(nil)
+	Emit(testEvent(), logger)
+	Emit(testEvent(), logger)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("Unable to parse event line: %s", err)
+		}
+		if e.Responder != "restart-httpd" || e.Outcome != "failure" || e.Host != "web01:22" {
+			t.Errorf("Unexpected event: %v", e)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("Expected 2 events, got %d", lines)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s, err := New(config.EventSink{Name: "syslog", Type: "syslog",
+		Syslog: config.SyslogEventSink{Network: "udp", Address: conn.LocalAddr().String(), Tag: "test"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer s.Close()
+	if err := s.Emit(testEvent()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	buffer := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	if err != nil {
+		t.Fatalf("Unexpected error reading syslog message: %s", err)
+	}
+	msg := string(buffer[:n])
+	// LOG_DAEMON|LOG_WARNING is priority 28
+	if !strings.HasPrefix(msg, "<28>") || !strings.Contains(msg, "test[") || !strings.Contains(msg, `"responder":"restart-httpd"`) {
+		t.Errorf("Unexpected syslog message: %s", msg)
+	}
+}
+
+func TestOTLPSink(t *testing.T) {
+	var request otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Unexpected authorization header: %s", r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Unexpected error decoding request: %s", err)
+		}
+	}))
+	defer server.Close()
+	s, err := New(config.EventSink{Name: "otlp", Type: "otlp", OTLP: config.OTLPEventSink{
+		Endpoint: server.URL + "/v1/logs",
+		Headers:  map[string]config.Secret{"Authorization": "Bearer token"},
+		Timeout:  time.Second,
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := s.Emit(testEvent()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(request.ResourceLogs) != 1 || len(request.ResourceLogs[0].ScopeLogs) != 1 ||
+		len(request.ResourceLogs[0].ScopeLogs[0].LogRecords) != 1 {
+		t.Fatalf("Unexpected request: %v", request)
+	}
+	record := request.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if record.TimeUnixNano != "1700000000000000000" {
+		t.Errorf("Unexpected time: %s", record.TimeUnixNano)
+	}
+	if record.SeverityText != "ERROR" {
+		t.Errorf("Unexpected severity: %s", record.SeverityText)
+	}
+	attributes := make(map[string]string)
+	for _, a := range record.Attributes {
+		if a.Value.StringValue != nil {
+			attributes[a.Key] = *a.Value.StringValue
+		}
+	}
+	if attributes["responder"] != "restart-httpd" || attributes["labels.host"] != "web01" {
+		t.Errorf("Unexpected attributes: %v", attributes)
+	}
+}
+
+func TestEmitSlowSink(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	if err := Configure([]config.EventSink{{Name: "otlp", Type: "otlp", OTLP: config.OTLPEventSink{
+		Endpoint: server.URL, Timeout: 10 * time.Second}}}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer Configure(nil)
+	go Emit(testEvent(), logger)
+	<-started
+
+	// Reloading while an event is being sent to the old sinks does not block events to the new sinks
+	path := filepath.Join(t.TempDir(), "events.log")
+	configured := make(chan error, 1)
+	go func() {
+		configured <- Configure([]config.EventSink{{Name: "file", Type: "file", Path: path}})
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sinksLock.Lock()
+		swapped := len(active.sinks) == 1 && active.sinks[0].Name() == "file"
+		sinksLock.Unlock()
+		if swapped {
+			break
+		}
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("Sinks were not replaced while an event was being sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	emitted := make(chan struct{})
+	go func() {
+		Emit(testEvent(), logger)
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(2 * time.Second):
+		t.Errorf("Emit was blocked by a slow sink")
+	}
+	select {
+	case <-configured:
+		t.Errorf("Expected old sinks to be closed after the event being sent")
+	default:
+	}
+	close(release)
+	if err := <-configured; err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if buffer, err := os.ReadFile(path); err != nil || strings.Count(string(buffer), "\n") != 1 {
+		t.Errorf("Unexpected events file %q: %v", buffer, err)
+	}
+}
+
+func TestConfigureError(t *testing.T) {
+	err := Configure([]config.EventSink{{Name: "file", Type: "file", Path: "/dne/events.log"}})
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// fileSink appends events as JSON lines to a file.
+type fileSink struct {
+	name string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileSink(c config.EventSink) (*fileSink, error) {
+	f, err := os.OpenFile(c.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{name: c.Name, file: f}, nil
+}
+
+func (s *fileSink) Name() string {
+	return s.name
+}
+
+func (s *fileSink) Emit(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
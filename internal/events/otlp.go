@@ -0,0 +1,166 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+const (
+	otlpScope         = "alertmanager-command-responder"
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// otlpSink sends events as log records to an OTLP/HTTP logs endpoint using the JSON encoding.
+type otlpSink struct {
+	config config.EventSink
+}
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpInstrumentationScope `json:"scope"`
+	LogRecords []otlpLogRecord          `json:"logRecords"`
+}
+
+type otlpInstrumentationScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber"`
+	SeverityText   string          `json:"severityText"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func stringValue(value string) otlpValue {
+	return otlpValue{StringValue: &value}
+}
+
+func (s *otlpSink) Name() string {
+	return s.config.Name
+}
+
+func (s *otlpSink) Emit(e Event) error {
+	timedOut := e.TimedOut
+	duration := e.Duration
+	record := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(e.Time.UnixNano(), 10),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body:           stringValue("Command completed"),
+		Attributes: []otlpAttribute{
+			{Key: "responder", Value: stringValue(e.Responder)},
+			{Key: "fingerprint", Value: stringValue(e.Fingerprint)},
+			{Key: "status", Value: stringValue(e.Status)},
+			{Key: "type", Value: stringValue(e.Type)},
+			{Key: "command", Value: stringValue(e.Command)},
+			{Key: "host", Value: stringValue(e.Host)},
+			{Key: "auth", Value: stringValue(e.Auth)},
+			{Key: "outcome", Value: stringValue(e.Outcome)},
+			{Key: "error", Value: stringValue(e.Error)},
+			{Key: "reason", Value: stringValue(e.Reason)},
+			{Key: "timed_out", Value: otlpValue{BoolValue: &timedOut}},
+			{Key: "duration", Value: otlpValue{DoubleValue: &duration}},
+		},
+	}
+	var names []string
+	for name := range e.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		record.Attributes = append(record.Attributes, otlpAttribute{Key: "labels." + name, Value: stringValue(e.Labels[name])})
+	}
+	if e.Outcome == "suppressed" {
+		record.SeverityNumber = otlpSeverityWarn
+		record.SeverityText = "WARN"
+		record.Body = stringValue("Command suppressed")
+	} else if e.Outcome != "success" {
+		record.SeverityNumber = otlpSeverityError
+		record.SeverityText = "ERROR"
+	}
+	request := otlpRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{{Key: "service.name", Value: stringValue(otlpScope)}},
+			},
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpInstrumentationScope{Name: otlpScope},
+				LogRecords: []otlpLogRecord{record},
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.OTLP.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.OTLP.Endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.config.OTLP.Headers {
+		req.Header.Set(k, string(v))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP request to %s returned %d", s.config.OTLP.Endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *otlpSink) Close() error {
+	return nil
+}
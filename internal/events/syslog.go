@@ -0,0 +1,58 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"log/syslog"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// syslogSink sends events as JSON messages to syslog.
+type syslogSink struct {
+	name   string
+	writer *syslog.Writer
+}
+
+func newSyslogSink(c config.EventSink) (*syslogSink, error) {
+	tag := c.Syslog.Tag
+	if tag == "" {
+		tag = "alertmanager-command-responder"
+	}
+	writer, err := syslog.Dial(c.Syslog.Network, c.Syslog.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{name: c.Name, writer: writer}, nil
+}
+
+func (s *syslogSink) Name() string {
+	return s.name
+}
+
+func (s *syslogSink) Emit(e Event) error {
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.Outcome != "success" {
+		return s.writer.Warning(string(msg))
+	}
+	return s.writer.Info(string(msg))
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
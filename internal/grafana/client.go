@@ -0,0 +1,86 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grafana posts annotations to the Grafana HTTP API.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+type Annotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	PanelID      int64    `json:"panelId,omitempty"`
+	Time         int64    `json:"time"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+type Client struct {
+	config config.GrafanaConfig
+}
+
+func NewClient(c config.GrafanaConfig) *Client {
+	return &Client{config: c}
+}
+
+// CreateAnnotation posts the annotation and returns its ID.
+func (c *Client) CreateAnnotation(ctx context.Context, annotation Annotation) (int64, error) {
+	if annotation.DashboardUID == "" {
+		annotation.DashboardUID = c.config.DashboardUID
+	}
+	if annotation.PanelID == 0 {
+		annotation.PanelID = c.config.PanelID
+	}
+	annotation.Tags = append(annotation.Tags, c.config.Tags...)
+	body, err := json.Marshal(annotation)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	url := strings.TrimSuffix(c.config.URL, "/") + "/api/annotations"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+string(c.config.Token))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Grafana request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("Grafana annotation request returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var response struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("Unable to parse Grafana response: %v", err)
+	}
+	return response.ID, nil
+}
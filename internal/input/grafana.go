@@ -0,0 +1,39 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// decodeGrafana reads a Grafana unified alerting webhook notification.
+// The payload extends the Alertmanager payload, older Grafana versions do not send alert fingerprints.
+func decodeGrafana(in config.Input, r io.Reader) (Message, error) {
+	var msg Message
+	if err := json.NewDecoder(r).Decode(&msg); err != nil {
+		return msg, err
+	}
+	if msg.Receiver == "" {
+		msg.Receiver = in.Name
+	}
+	for i := range msg.Alerts {
+		if msg.Alerts[i].Fingerprint == "" {
+			msg.Alerts[i].Fingerprint = fingerprint(msg.Alerts[i].Labels)
+		}
+	}
+	return msg, nil
+}
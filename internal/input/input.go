@@ -0,0 +1,57 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// Message is a notification normalized to the payload of the Alertmanager webhook receiver.
+type Message struct {
+	template.Data
+	Version         string `json:"version"`
+	GroupKey        string `json:"groupKey"`
+	TruncatedAlerts uint64 `json:"truncatedAlerts"`
+}
+
+// Decode reads a notification in the format of the input.
+func Decode(in config.Input, r io.Reader) (Message, error) {
+	switch in.Type {
+	case "", "alertmanager":
+		var msg Message
+		err := json.NewDecoder(r).Decode(&msg)
+		return msg, err
+	case "grafana":
+		return decodeGrafana(in, r)
+	case "json":
+		return decodeJSON(in, r)
+	default:
+		return Message{}, fmt.Errorf("Unsupported input type: %s", in.Type)
+	}
+}
+
+// fingerprint returns the Alertmanager fingerprint of the labels.
+func fingerprint(labels template.KV) string {
+	labelSet := model.LabelSet{}
+	for k, v := range labels {
+		labelSet[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return labelSet.Fingerprint().String()
+}
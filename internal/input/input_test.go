@@ -0,0 +1,138 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func TestDecodeAlertmanager(t *testing.T) {
+	payload := `{"version": "4", "groupKey": "{}:{}", "truncatedAlerts": 2, "receiver": "responder", "status": "firing",
+		"alerts": [{"status": "firing", "labels": {"alertname": "foo"}, "fingerprint": "abc"}]}`
+	msg, err := Decode(config.Input{Type: "alertmanager"}, strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if msg.GroupKey != "{}:{}" || msg.TruncatedAlerts != 2 || msg.Receiver != "responder" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if len(msg.Alerts) != 1 || msg.Alerts[0].Fingerprint != "abc" {
+		t.Errorf("Unexpected alerts: %+v", msg.Alerts)
+	}
+	if _, err := Decode(config.Input{Type: "dne"}, strings.NewReader(payload)); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestDecodeGrafana(t *testing.T) {
+	payload := `{"receiver": "", "status": "firing", "orgId": 1, "state": "alerting", "title": "[FIRING:1] HighLoad",
+		"alerts": [{"status": "firing", "labels": {"alertname": "HighLoad", "grafana_folder": "hosts", "host": "node1"},
+		"annotations": {"cr_local_cmd": "uptime"}, "startsAt": "2023-01-01T00:00:00Z", "values": {"B": 12.5},
+		"dashboardURL": "http://grafana/d/abc"}],
+		"groupLabels": {"alertname": "HighLoad"}, "commonLabels": {"alertname": "HighLoad"}, "externalURL": "http://grafana/"}`
+	msg, err := Decode(config.Input{Name: "grafana", Type: "grafana"}, strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if msg.Receiver != "grafana" || msg.ExternalURL != "http://grafana/" || msg.GroupLabels["alertname"] != "HighLoad" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if len(msg.Alerts) != 1 {
+		t.Fatalf("Unexpected alerts: %+v", msg.Alerts)
+	}
+	a := msg.Alerts[0]
+	if a.Fingerprint != fingerprint(a.Labels) || a.Annotations["cr_local_cmd"] != "uptime" || a.StartsAt.Year() != 2023 {
+		t.Errorf("Unexpected alert: %+v", a)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	in := config.Input{
+		Name: "app",
+		Type: "json",
+		JSON: config.JSONInput{
+			Alerts:            "$.events",
+			Status:            "$.state",
+			StatusMap:         map[string]string{"alerting": "firing", "ok": "resolved"},
+			Fingerprint:       "$.id",
+			StartsAt:          "$.time",
+			LabelsFrom:        "$.tags",
+			AnnotationsFrom:   "$.details",
+			Labels:            map[string]string{"alertname": "$.check", "severity": "$.level"},
+			Annotations:       map[string]string{"summary": "$.message"},
+			StaticLabels:      map[string]string{"source": "app"},
+			StaticAnnotations: map[string]string{"cr_local_cmd": "echo {{ .Labels.host }}"},
+		},
+	}
+	payload := `{"events": [
+		{"id": 1, "check": "disk", "state": "alerting", "level": 2, "time": 1672531200, "tags": {"host": "node1"}, "message": "Disk full",
+			"details": {"runbook": "disk", "cr_ssh_cmd": "rm -rf /"}},
+		{"id": 2, "check": "disk", "state": "ok", "time": "2023-01-01T00:00:00Z", "tags": {"host": "node2", "source": "other"}}]}`
+	msg, err := Decode(in, strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if msg.Receiver != "app" || msg.Status != "firing" || len(msg.Alerts) != 2 {
+		t.Fatalf("Unexpected message: %+v", msg)
+	}
+	a := msg.Alerts[0]
+	if a.Status != "firing" || a.Fingerprint != "1" || a.Labels["alertname"] != "disk" || a.Labels["host"] != "node1" ||
+		a.Labels["severity"] != "2" || a.Labels["source"] != "app" || a.Annotations["summary"] != "Disk full" ||
+		a.Annotations["cr_local_cmd"] != "echo {{ .Labels.host }}" || a.Annotations["runbook"] != "disk" {
+		t.Errorf("Unexpected alert: %+v", a)
+	}
+	if _, ok := a.Annotations["cr_ssh_cmd"]; ok {
+		t.Errorf("Expected responder annotations from the payload to be ignored: %v", a.Annotations)
+	}
+	expected := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !a.StartsAt.Equal(expected) || !msg.Alerts[1].StartsAt.Equal(expected) {
+		t.Errorf("Unexpected starts at: %v %v", a.StartsAt, msg.Alerts[1].StartsAt)
+	}
+	if msg.Alerts[1].Status != "resolved" || msg.Alerts[1].Labels["source"] != "app" {
+		t.Errorf("Unexpected alert: %+v", msg.Alerts[1])
+	}
+	if len(msg.CommonLabels) != 2 || msg.CommonLabels["alertname"] != "disk" || msg.CommonLabels["source"] != "app" {
+		t.Errorf("Unexpected common labels: %v", msg.CommonLabels)
+	}
+
+	in.JSON.Alerts = "$"
+	msg, err = Decode(in, strings.NewReader(`{"check": "load", "state": "alerting"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(msg.Alerts) != 1 || msg.Alerts[0].Fingerprint != fingerprint(msg.Alerts[0].Labels) {
+		t.Errorf("Unexpected alerts: %+v", msg.Alerts)
+	}
+
+	errors := []string{
+		`{"check": "load", "state": "unknown"}`,
+		`{"check": "load"}`,
+		`{"check": "load", "state": "ok", "tags": "foo"}`,
+		`{"check": "load", "state": "ok", "time": "yesterday"}`,
+		`{"check": "load"`,
+	}
+	for _, payload := range errors {
+		if _, err := Decode(in, strings.NewReader(payload)); err == nil {
+			t.Errorf("Expected an error for %s", payload)
+		}
+	}
+	in.JSON.Alerts = "$.dne"
+	if _, err := Decode(in, strings.NewReader(`{}`)); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
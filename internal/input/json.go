@@ -0,0 +1,221 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/jsonpath"
+)
+
+// decodeJSON reads a generic JSON payload and maps it to alerts using the JSONPath expressions of the input.
+func decodeJSON(in config.Input, r io.Reader) (Message, error) {
+	msg := Message{Data: template.Data{Receiver: in.Name, Status: "resolved"}}
+	var payload interface{}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return msg, err
+	}
+	root, ok, err := lookup(in.JSON.Alerts, payload)
+	if err != nil {
+		return msg, err
+	}
+	if !ok {
+		return msg, fmt.Errorf("Alerts not found in payload: %s", in.JSON.Alerts)
+	}
+	items, ok := root.([]interface{})
+	if !ok {
+		items = []interface{}{root}
+	}
+	for i, item := range items {
+		a, err := jsonAlert(in.JSON, item)
+		if err != nil {
+			return msg, fmt.Errorf("Alert %d: %v", i, err)
+		}
+		if a.Status == "firing" {
+			msg.Status = "firing"
+		}
+		msg.Alerts = append(msg.Alerts, a)
+	}
+	msg.GroupLabels = template.KV{}
+	msg.CommonLabels = commonKV(msg.Alerts, func(a template.Alert) template.KV { return a.Labels })
+	msg.CommonAnnotations = commonKV(msg.Alerts, func(a template.Alert) template.KV { return a.Annotations })
+	return msg, nil
+}
+
+func jsonAlert(c config.JSONInput, item interface{}) (template.Alert, error) {
+	a := template.Alert{
+		Status:      "firing",
+		Labels:      template.KV{},
+		Annotations: template.KV{},
+	}
+	if err := mapKV(a.Labels, c.LabelsFrom, "", c.Labels, c.StaticLabels, item); err != nil {
+		return a, err
+	}
+	if err := mapKV(a.Annotations, c.AnnotationsFrom, responderAnnotationPrefix, c.Annotations, c.StaticAnnotations, item); err != nil {
+		return a, err
+	}
+	if c.Status != "" {
+		val, ok, err := lookup(c.Status, item)
+		if err != nil {
+			return a, err
+		}
+		if !ok {
+			return a, fmt.Errorf("Status not found: %s", c.Status)
+		}
+		status := stringValue(val)
+		if mapped, ok := c.StatusMap[status]; ok {
+			status = mapped
+		}
+		if status != "firing" && status != "resolved" {
+			return a, fmt.Errorf("Unsupported status: %s", status)
+		}
+		a.Status = status
+	}
+	var err error
+	if a.StartsAt, err = timeValue(c.StartsAt, item); err != nil {
+		return a, err
+	}
+	if a.EndsAt, err = timeValue(c.EndsAt, item); err != nil {
+		return a, err
+	}
+	if val, ok, err := lookup(c.GeneratorURL, item); err != nil {
+		return a, err
+	} else if ok {
+		a.GeneratorURL = stringValue(val)
+	}
+	if val, ok, err := lookup(c.Fingerprint, item); err != nil {
+		return a, err
+	} else if ok {
+		a.Fingerprint = stringValue(val)
+	}
+	if a.Fingerprint == "" {
+		a.Fingerprint = fingerprint(a.Labels)
+	}
+	return a, nil
+}
+
+// Annotations that configure the responder are never copied from the payload by annotations_from
+// so the sender of the payload can not choose the commands that run.
+const responderAnnotationPrefix = "cr_"
+
+// mapKV sets the members of the object at from, except those starting with skipPrefix, then the mapped paths and then the static values.
+func mapKV(kv template.KV, from string, skipPrefix string, paths map[string]string, static map[string]string, item interface{}) error {
+	val, ok, err := lookup(from, item)
+	if err != nil {
+		return err
+	}
+	if ok {
+		obj, isObject := val.(map[string]interface{})
+		if !isObject {
+			return fmt.Errorf("Value of %s is not an object", from)
+		}
+		for k, v := range obj {
+			if skipPrefix != "" && strings.HasPrefix(k, skipPrefix) {
+				continue
+			}
+			kv[k] = stringValue(v)
+		}
+	}
+	for name, path := range paths {
+		val, ok, err := lookup(path, item)
+		if err != nil {
+			return err
+		}
+		if ok {
+			kv[name] = stringValue(val)
+		}
+	}
+	for k, v := range static {
+		kv[k] = v
+	}
+	return nil
+}
+
+// lookup returns the value at the path, an empty path is never found.
+func lookup(path string, data interface{}) (interface{}, bool, error) {
+	if path == "" {
+		return nil, false, nil
+	}
+	p, err := jsonpath.Parse(path)
+	if err != nil {
+		return nil, false, err
+	}
+	val, ok := p.Get(data)
+	return val, ok, nil
+}
+
+func stringValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		buffer, _ := json.Marshal(v)
+		return string(buffer)
+	}
+}
+
+// timeValue parses an RFC 3339 timestamp or a number of seconds since the epoch.
+func timeValue(path string, item interface{}) (time.Time, error) {
+	val, ok, err := lookup(path, item)
+	if err != nil || !ok || val == nil {
+		return time.Time{}, err
+	}
+	if n, ok := val.(json.Number); ok {
+		seconds, err := n.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Unable to parse time %s: %v", n, err)
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	t, err := time.Parse(time.RFC3339, stringValue(val))
+	if err != nil {
+		return t, fmt.Errorf("Unable to parse time %s: %v", stringValue(val), err)
+	}
+	return t, nil
+}
+
+// commonKV returns the pairs with the same value in every alert.
+func commonKV(alerts []template.Alert, kv func(template.Alert) template.KV) template.KV {
+	common := template.KV{}
+	if len(alerts) == 0 {
+		return common
+	}
+	for k, v := range kv(alerts[0]) {
+		common[k] = v
+	}
+	for _, a := range alerts[1:] {
+		pairs := kv(a)
+		for k, v := range common {
+			if val, ok := pairs[k]; !ok || val != v {
+				delete(common, k)
+			}
+		}
+	}
+	return common
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonpath evaluates a subset of JSONPath against decoded JSON.
+// Supported are $ for the root, .name and ['name'] for object members and [n] for array elements.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Path []step
+
+type step struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// Parse parses a JSONPath expression.
+func Parse(path string) (Path, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath must start with $: %s", path)
+	}
+	var p Path
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("Empty member name in JSONPath: %s", path)
+			}
+			p = append(p, step{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("Unclosed bracket in JSONPath: %s", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				p = append(p, step{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("Invalid index %s in JSONPath: %s", inner, path)
+			}
+			p = append(p, step{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("Unexpected character %q in JSONPath: %s", rest[0], path)
+		}
+	}
+	return p, nil
+}
+
+// Get returns the value at the path and whether it exists.
+func (p Path) Get(data interface{}) (interface{}, bool) {
+	for _, s := range p {
+		if s.isIndex {
+			list, ok := data.([]interface{})
+			if !ok || s.index >= len(list) {
+				return nil, false
+			}
+			data = list[s.index]
+			continue
+		}
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		data, ok = obj[s.key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	var data interface{}
+	payload := `{"alerts": [{"name": "foo", "tags": {"host.name": "node1"}}], "count": 1}`
+	if err := json.Unmarshal([]byte(payload), &data); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]interface{}{
+		"$.count":                             float64(1),
+		"$.alerts[0].name":                    "foo",
+		"$['alerts'][0]['tags']['host.name']": "node1",
+		`$.alerts[0].tags["host.name"]`:       "node1",
+	}
+	for path, expected := range tests {
+		p, err := Parse(path)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", path, err)
+			continue
+		}
+		val, ok := p.Get(data)
+		if !ok || val != expected {
+			t.Errorf("Unexpected value for %s, got: %v", path, val)
+		}
+	}
+	p, _ := Parse("$")
+	if val, ok := p.Get(data); !ok || val.(map[string]interface{})["count"] != float64(1) {
+		t.Errorf("Unexpected value for root, got: %v", val)
+	}
+	for _, path := range []string{"$.dne", "$.alerts[1]", "$.count.foo", "$.alerts.name"} {
+		p, err := Parse(path)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", path, err)
+			continue
+		}
+		if val, ok := p.Get(data); ok {
+			t.Errorf("Expected %s to not exist, got: %v", path, val)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, path := range []string{"alerts", "$..foo", "$.foo[", "$.foo[-1]", "$.foo[bar]", "$foo"} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("Expected an error parsing %s", path)
+		}
+	}
+}
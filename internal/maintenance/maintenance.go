@@ -0,0 +1,172 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package maintenance keeps maintenance windows that suppress commands for alerts matching label matchers.
+// Windows are saved to a YAML file when configured so they can also be managed with configuration management.
+package maintenance
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	lock    sync.Mutex
+	path    string
+	windows []Window
+)
+
+// Window suppresses commands for alerts matching all matchers between StartsAt and EndsAt.
+// Matchers use the Alertmanager syntax, for example instance=~"web.*".
+// A zero StartsAt starts the window immediately and a zero EndsAt never ends it.
+type Window struct {
+	ID        string    `yaml:"id" json:"id"`
+	Matchers  []string  `yaml:"matchers" json:"matchers"`
+	StartsAt  time.Time `yaml:"starts_at,omitempty" json:"starts_at,omitempty"`
+	EndsAt    time.Time `yaml:"ends_at,omitempty" json:"ends_at,omitempty"`
+	Comment   string    `yaml:"comment,omitempty" json:"comment,omitempty"`
+	CreatedBy string    `yaml:"created_by,omitempty" json:"created_by,omitempty"`
+	matchers  []*labels.Matcher
+}
+
+// Active returns true if the window applies at the time.
+func (w Window) Active(t time.Time) bool {
+	return !t.Before(w.StartsAt) && (w.EndsAt.IsZero() || t.Before(w.EndsAt))
+}
+
+// Matches returns true if the labels match all matchers of the window.
+func (w Window) Matches(l map[string]string) bool {
+	for _, m := range w.matchers {
+		if !m.Matches(l[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate parses the matchers and sets the ID if it is not set.
+func (w *Window) validate() error {
+	if len(w.Matchers) == 0 {
+		return fmt.Errorf("Maintenance window requires at least one matcher")
+	}
+	w.matchers = nil
+	for _, m := range w.Matchers {
+		matcher, err := labels.ParseMatcher(m)
+		if err != nil {
+			return fmt.Errorf("Invalid maintenance window matcher %s: %v", m, err)
+		}
+		w.matchers = append(w.matchers, matcher)
+	}
+	if !w.EndsAt.IsZero() && !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("Maintenance window must end after it starts")
+	}
+	if w.ID == "" {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s", strings.Join(w.Matchers, ","), w.StartsAt, w.EndsAt, w.Comment)))
+		w.ID = fmt.Sprintf("%x", hash[:8])
+	}
+	return nil
+}
+
+// Configure loads the windows from the file, with an empty path windows are only kept in memory.
+// The file is read every time so changes made to it are picked up when the configuration reloads.
+func Configure(p string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	loaded := windows
+	if p != "" {
+		var err error
+		loaded, err = load(p)
+		if err != nil {
+			return err
+		}
+	}
+	path = p
+	windows = loaded
+	return nil
+}
+
+// List returns all windows, including those that have not started or have ended.
+func List() []Window {
+	lock.Lock()
+	defer lock.Unlock()
+	return append([]Window{}, windows...)
+}
+
+// Add validates and adds the window, replacing any window with the same ID.
+// Windows that have ended are removed.
+func Add(w Window) (Window, error) {
+	if err := w.validate(); err != nil {
+		return w, err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	now := time.Now()
+	updated := []Window{}
+	for _, existing := range windows {
+		if existing.ID == w.ID || (!existing.EndsAt.IsZero() && !now.Before(existing.EndsAt)) {
+			continue
+		}
+		updated = append(updated, existing)
+	}
+	windows = append(updated, w)
+	return w, save()
+}
+
+// Delete removes the window with the ID, false is returned if it does not exist.
+func Delete(id string) (bool, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	for i, w := range windows {
+		if w.ID == id {
+			windows = append(windows[:i:i], windows[i+1:]...)
+			return true, save()
+		}
+	}
+	return false, nil
+}
+
+// Match returns the first window active at the time that matches the labels.
+func Match(l map[string]string, t time.Time) (Window, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, w := range windows {
+		if w.Active(t) && w.Matches(l) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+func load(p string) ([]Window, error) {
+	var loaded []Window
+	if err := utils.LoadFile(p, "maintenance file", &loaded, yaml.Unmarshal); err != nil {
+		return nil, err
+	}
+	for i := range loaded {
+		if err := loaded[i].validate(); err != nil {
+			return nil, fmt.Errorf("Maintenance file %s: %v", p, err)
+		}
+	}
+	return loaded, nil
+}
+
+func save() error {
+	return utils.SaveFile(path, windows, yaml.Marshal)
+}
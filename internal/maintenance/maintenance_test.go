@@ -0,0 +1,86 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/testutils"
+)
+
+func TestMaintenance(t *testing.T) {
+	p := testutils.ConfigureFile(t, "maintenance.yaml", Configure)
+	defer func() {
+		windows = nil
+	}()
+	now := time.Now()
+	w, err := Add(Window{Matchers: []string{`alertname="NodeDown"`, `instance=~"web.*"`}, EndsAt: now.Add(time.Hour), Comment: "Patching"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if w.ID == "" {
+		t.Errorf("Expected ID to be set")
+	}
+	if _, ok := Match(map[string]string{"alertname": "NodeDown", "instance": "web1"}, now); !ok {
+		t.Errorf("Expected window to match")
+	}
+	if _, ok := Match(map[string]string{"alertname": "NodeDown", "instance": "db1"}, now); ok {
+		t.Errorf("Expected window not to match other instance")
+	}
+	if _, ok := Match(map[string]string{"alertname": "NodeDown", "instance": "web1"}, now.Add(2*time.Hour)); ok {
+		t.Errorf("Expected window not to match after it ends")
+	}
+	for _, invalid := range []Window{
+		{},
+		{Matchers: []string{`instance=~"web`}},
+		{Matchers: []string{`instance="web1"`}, StartsAt: now, EndsAt: now.Add(-time.Hour)},
+	} {
+		if _, err := Add(invalid); err == nil {
+			t.Errorf("Expected error adding %+v", invalid)
+		}
+	}
+
+	// Windows are loaded from the file
+	windows = nil
+	if err := Configure(p); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if l := List(); len(l) != 1 || l[0].ID != w.ID || l[0].Comment != "Patching" {
+		t.Errorf("Unexpected windows: %+v", l)
+	}
+	if _, ok := Match(map[string]string{"alertname": "NodeDown", "instance": "web2"}, now); !ok {
+		t.Errorf("Expected loaded window to match")
+	}
+	if ok, err := Delete(w.ID); !ok || err != nil {
+		t.Errorf("Unexpected delete result %v: %v", ok, err)
+	}
+	if ok, _ := Delete(w.ID); ok {
+		t.Errorf("Expected deleting missing window to return false")
+	}
+	if len(List()) != 0 {
+		t.Errorf("Expected no windows")
+	}
+
+	// Windows without an ID in the file get one
+	if err := Configure(testutils.WriteFile(t, "maintenance.yaml", "- matchers: ['alertname=\"NodeDown\"']\n")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if l := List(); len(l) != 1 || l[0].ID == "" {
+		t.Errorf("Unexpected windows: %+v", l)
+	}
+	if err := Configure(testutils.WriteFile(t, "maintenance.yaml", "- matchers: []\n")); err == nil {
+		t.Errorf("Expected error loading invalid window")
+	}
+}
@@ -42,12 +42,70 @@ var (
 		Name:      "command_errors_total",
 		Help:      "Total number of command errors",
 	}, []string{"type"})
+	DryRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dry_runs_total",
+		Help:      "Total number of commands not executed because of dry run",
+	}, []string{"type"})
+	NotificationErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_errors_total",
+		Help:      "Total number of errors sending notifications",
+	}, []string{"notifier"})
+	EventErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_errors_total",
+		Help:      "Total number of errors emitting execution events",
+	}, []string{"sink"})
+	TruncatedAlertsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "truncated_alerts_total",
+		Help:      "Total number of alerts truncated by Alertmanager from notifications",
+	}, []string{"receiver"})
+	SuppressedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "suppressed_total",
+		Help:      "Total number of alerts whose commands were suppressed",
+	}, []string{"reason"})
+	Paused = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "paused",
+		Help:      "Whether commands are paused, the global pause has an empty responder label",
+	}, []string{"responder"})
+	CircuitBreakerTripped = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_tripped",
+		Help:      "Whether the circuit breaker of the responder has tripped",
+	}, []string{"responder"})
+	PollErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "poll_errors_total",
+		Help:      "Total number of errors polling Alertmanager for alerts",
+	})
+	PollTrackedAlerts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "poll_tracked_alerts",
+		Help:      "Number of firing alerts tracked by polling Alertmanager",
+	})
+	ConfigLastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration reload attempt was successful",
+	})
+	ConfigLastReloadSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration reload",
+	})
 )
 
 func MetricsInit() {
 	BuildInfo.Set(1)
 	CommandErrorsTotal.WithLabelValues("ssh")
 	CommandErrorsTotal.WithLabelValues("local")
+	DryRunsTotal.WithLabelValues("ssh")
+	DryRunsTotal.WithLabelValues("local")
+	Paused.WithLabelValues("")
 }
 
 func Metrics() prometheus.Gatherers {
@@ -55,6 +113,17 @@ func Metrics() prometheus.Gatherers {
 	registry.MustRegister(BuildInfo)
 	registry.MustRegister(ErrorsTotal)
 	registry.MustRegister(CommandErrorsTotal)
+	registry.MustRegister(DryRunsTotal)
+	registry.MustRegister(NotificationErrorsTotal)
+	registry.MustRegister(EventErrorsTotal)
+	registry.MustRegister(TruncatedAlertsTotal)
+	registry.MustRegister(SuppressedTotal)
+	registry.MustRegister(Paused)
+	registry.MustRegister(CircuitBreakerTripped)
+	registry.MustRegister(PollErrorsTotal)
+	registry.MustRegister(PollTrackedAlerts)
+	registry.MustRegister(ConfigLastReloadSuccessful)
+	registry.MustRegister(ConfigLastReloadSuccessTimestamp)
 	gatherers := prometheus.Gatherers{registry}
 	gatherers = append(gatherers, prometheus.DefaultGatherer)
 	return gatherers
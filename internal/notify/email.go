@@ -0,0 +1,126 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// emailNotifier sends the message text by SMTP.
+type emailNotifier struct {
+	config config.Notifier
+}
+
+func (n *emailNotifier) Name() string {
+	return n.config.Name
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, msg Message) error {
+	c := n.config.Email
+	subject, err := render(n.config.Name, c.Subject, defaultSubject, msg)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", headerValue(c.From))
+	fmt.Fprintf(&body, "To: %s\r\n", headerValue(strings.Join(c.To, ", ")))
+	fmt.Fprintf(&body, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	body.WriteString("\r\n")
+
+	host, _, err := net.SplitHostPort(c.Smarthost)
+	if err != nil {
+		return fmt.Errorf("Notifier %s has invalid smarthost %s: %v", n.config.Name, c.Smarthost, err)
+	}
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, string(c.Password), host)
+	}
+	if err := sendMail(ctx, c.Smarthost, host, auth, c.From, c.To, body.Bytes()); err != nil {
+		var netErr net.Error
+		if ctx.Err() != nil || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return fmt.Errorf("Notifier %s timed out sending email: %v", n.config.Name, err)
+		}
+		return fmt.Errorf("Notifier %s unable to send email: %v", n.config.Name, err)
+	}
+	return nil
+}
+
+// headerValue replaces line breaks so values can not add headers to the email.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+}
+
+// sendMail is smtp.SendMail with the connection deadline set from the context
+// so a slow server can not keep the connection open after the timeout.
+func sendMail(ctx context.Context, addr string, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
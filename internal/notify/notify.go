@@ -0,0 +1,93 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+const (
+	defaultTemplate = `[{{ .Outcome }}] {{ .Responder }} {{ .Status }}{{ if .Reason }}: {{ .Reason }}{{ end }}{{ range .Results }}
+{{ .Type }}{{ if .Host }} {{ .Host }}{{ end }}: {{ .Command }}{{ if .Error }} error: {{ .Error }}{{ end }}{{ end }}`
+	defaultSubject = `[{{ .Outcome }}] {{ .Responder }} {{ .Status }}`
+)
+
+// Message describes the outcome of running the commands for an alert.
+// Results holds the command results and can be ranged over in templates.
+// Reason explains outcomes where commands did not run, such as a tripped circuit breaker.
+type Message struct {
+	Responder   string            `json:"responder"`
+	Fingerprint string            `json:"fingerprint"`
+	Status      string            `json:"status"`
+	Outcome     string            `json:"outcome"`
+	Reason      string            `json:"reason,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Results     interface{}       `json:"results"`
+	Text        string            `json:"text"`
+}
+
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier for the configuration.
+func New(c config.Notifier) (Notifier, error) {
+	switch c.Type {
+	case "webhook":
+		return &webhookNotifier{config: c}, nil
+	case "slack":
+		return &slackNotifier{config: c}, nil
+	case "email":
+		return &emailNotifier{config: c}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported notifier type: %s", c.Type)
+	}
+}
+
+// Send renders the message text using the notifier template and sends it.
+func Send(c config.Notifier, msg Message) error {
+	n, err := New(c)
+	if err != nil {
+		return err
+	}
+	msg.Text, err = render(c.Name, c.Template, defaultTemplate, msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	return n.Notify(ctx, msg)
+}
+
+func render(name string, text string, defaultText string, msg Message) (string, error) {
+	if text == "" {
+		text = defaultText
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse template for notifier %s: %v", name, err)
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, msg); err != nil {
+		return "", fmt.Errorf("Unable to execute template for notifier %s: %v", name, err)
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+type testResult struct {
+	Type    string
+	Command string
+	Host    string
+	Error   string
+}
+
+func testMessage() Message {
+	return Message{
+		Responder:   "restart-httpd",
+		Fingerprint: "abc",
+		Status:      "firing",
+		Outcome:     "failure",
+		Labels:      map[string]string{"alertname": "restart-httpd", "host": "web01"},
+		Results: []testResult{
+			{Type: "ssh", Command: "systemctl restart httpd", Host: "web01:22", Error: "exit status 1"},
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Unexpected error decoding body: %s", err)
+		}
+	}))
+	defer server.Close()
+	c := config.Notifier{Name: "webhook", Type: "webhook", URL: config.Secret(server.URL), Timeout: time.Second}
+	if err := Send(c, testMessage()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if received.Responder != "restart-httpd" || received.Outcome != "failure" {
+		t.Errorf("Unexpected message: %v", received)
+	}
+	expected := "[failure] restart-httpd firing\nssh web01:22: systemctl restart httpd error: exit status 1"
+	if received.Text != expected {
+		t.Errorf("Unexpected text\nExpected: %s\nGot: %s", expected, received.Text)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Unexpected error decoding body: %s", err)
+		}
+	}))
+	defer server.Close()
+	c := config.Notifier{Name: "slack", Type: "slack", URL: config.Secret(server.URL), Timeout: time.Second,
+		Template: "{{ .Responder }} on {{ .Labels.host }} {{ .Outcome }}"}
+	if err := Send(c, testMessage()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if received["text"] != "restart-httpd on web01 failure" {
+		t.Errorf("Unexpected text: %s", received["text"])
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	c := config.Notifier{Name: "webhook", Type: "webhook", URL: config.Secret(server.URL), Timeout: time.Second}
+	err := Send(c, testMessage())
+	if err == nil || err.Error() != "Notifier webhook request returned 500" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	c.Template = "{{ .DoesNotExist }}"
+	if err := Send(c, testMessage()); err == nil {
+		t.Errorf("Expected template error")
+	}
+}
+
+func TestWebhookErrorHidesURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL + "/hooks/secret-token"
+	server.Close()
+	c := config.Notifier{Name: "webhook", Type: "webhook", URL: config.Secret(url), Timeout: time.Second}
+	err := Send(c, testMessage())
+	if err == nil {
+		t.Fatalf("Expected request error")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Error contains URL: %s", err)
+	}
+	c.URL = "http://[::1/secret-token"
+	err = Send(c, testMessage())
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	data := make(chan string, 1)
+	go smtpServer(t, listener, data)
+	c := config.Notifier{
+		Name:    "email",
+		Type:    "email",
+		Timeout: 2 * time.Second,
+		Email: config.EmailConfig{
+			Smarthost: listener.Addr().String(),
+			From:      "responder@example.com",
+			To:        []string{"ops@example.com"},
+		},
+	}
+	if err := Send(c, testMessage()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	select {
+	case body := <-data:
+		if !strings.Contains(body, "Subject: [failure] restart-httpd firing\r\n") {
+			t.Errorf("Unexpected subject in body: %s", body)
+		}
+		if !strings.Contains(body, "ssh web01:22: systemctl restart httpd error: exit status 1") {
+			t.Errorf("Unexpected body: %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Email was not received")
+	}
+
+	// Line breaks in header values can not add headers
+	go smtpServer(t, listener, data)
+	c.Email.Subject = "{{ .Labels.host }} is down"
+	msg := testMessage()
+	msg.Labels["host"] = "web01\r\nBcc: attacker@example.com\rX-Injected: true"
+	if err := Send(c, msg); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	select {
+	case body := <-data:
+		if !strings.Contains(body, "Subject: web01 Bcc: attacker@example.com X-Injected: true is down\r\n") {
+			t.Errorf("Unexpected subject in body: %s", body)
+		}
+		if strings.Contains(body, "\r\nBcc:") || strings.Contains(body, "\rX-Injected") {
+			t.Errorf("Unexpected injected header in body: %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Email was not received")
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	closed := make(chan struct{})
+	go func() {
+		// Accept the connection but never reply
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+	c := config.Notifier{
+		Name:    "email",
+		Type:    "email",
+		Timeout: 100 * time.Millisecond,
+		Email: config.EmailConfig{
+			Smarthost: listener.Addr().String(),
+			From:      "responder@example.com",
+			To:        []string{"ops@example.com"},
+		},
+	}
+	if err := Send(c, testMessage()); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected the connection to be closed after the timeout")
+	}
+}
+
+// smtpServer is a minimal SMTP server that accepts a single message.
+func smtpServer(t *testing.T, listener net.Listener, data chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		if _, err := conn.Write([]byte(line + "\r\n")); err != nil {
+			t.Errorf("Unexpected SMTP write error: %s", err)
+		}
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var body strings.Builder
+			for {
+				l, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			data <- body.String()
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+// webhookNotifier posts the message as JSON to a URL.
+type webhookNotifier struct {
+	config config.Notifier
+}
+
+// slackNotifier posts the message text to a Slack compatible incoming webhook.
+type slackNotifier struct {
+	config config.Notifier
+}
+
+func (n *webhookNotifier) Name() string {
+	return n.config.Name
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, msg Message) error {
+	return post(ctx, n.config, msg)
+}
+
+func (n *slackNotifier) Name() string {
+	return n.config.Name
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, msg Message) error {
+	return post(ctx, n.config, map[string]string{"text": msg.Text})
+}
+
+func post(ctx context.Context, c config.Notifier, body interface{}) error {
+	buffer, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, string(c.URL), bytes.NewBuffer(buffer))
+	if err != nil {
+		return fmt.Errorf("Notifier %s has invalid URL: %v", c.Name, redactURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Notifier %s request failed: %v", c.Name, redactURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Notifier %s request returned %d", c.Name, resp.StatusCode)
+	}
+	return nil
+}
+
+// redactURL removes the URL from the error, the URL of a notifier is a secret.
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
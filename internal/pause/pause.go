@@ -0,0 +1,140 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pause keeps whether commands are paused for all responders or individual responders.
+// The paused state is saved to a file when configured so it survives restarts.
+package pause
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+var (
+	lock       sync.Mutex
+	configured bool
+	path       string
+	current    = State{Responders: make(map[string]Entry)}
+)
+
+// State is the global pause and the pauses of individual responders.
+type State struct {
+	Global     *Entry           `json:"global,omitempty"`
+	Responders map[string]Entry `json:"responders"`
+}
+
+// Entry records when and by whom commands were paused.
+type Entry struct {
+	Time time.Time `json:"time"`
+	By   string    `json:"by,omitempty"`
+}
+
+// Configure loads the paused state from the file, with an empty path the state is only kept in memory.
+func Configure(p string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if configured && p == path {
+		return nil
+	}
+	loaded := current
+	if p != "" {
+		var err error
+		loaded, err = load(p)
+		if err != nil {
+			return err
+		}
+	}
+	configured = true
+	path = p
+	current = loaded
+	updateMetrics()
+	return nil
+}
+
+// Get returns a copy of the paused state.
+func Get() State {
+	lock.Lock()
+	defer lock.Unlock()
+	s := State{Global: current.Global, Responders: make(map[string]Entry)}
+	for name, p := range current.Responders {
+		s.Responders[name] = p
+	}
+	return s
+}
+
+// Paused returns true if commands are paused globally or for the responder.
+func Paused(responder string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	_, ok := current.Responders[responder]
+	return current.Global != nil || ok
+}
+
+// Pause pauses commands for the responder, or for all responders if responder is empty.
+func Pause(responder string, by string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	p := Entry{Time: time.Now(), By: by}
+	if responder == "" {
+		current.Global = &p
+	} else {
+		current.Responders[responder] = p
+	}
+	updateMetrics()
+	return save()
+}
+
+// Resume resumes commands for the responder, or the global pause if responder is empty.
+// Responders paused individually stay paused when the global pause is resumed.
+func Resume(responder string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if responder == "" {
+		current.Global = nil
+	} else {
+		delete(current.Responders, responder)
+	}
+	updateMetrics()
+	return save()
+}
+
+func updateMetrics() {
+	metrics.Paused.Reset()
+	if current.Global != nil {
+		metrics.Paused.WithLabelValues("").Set(1)
+	} else {
+		metrics.Paused.WithLabelValues("").Set(0)
+	}
+	for name := range current.Responders {
+		metrics.Paused.WithLabelValues(name).Set(1)
+	}
+}
+
+func load(p string) (State, error) {
+	loaded := State{Responders: make(map[string]Entry)}
+	if err := utils.LoadFile(p, "pause file", &loaded, json.Unmarshal); err != nil {
+		return loaded, err
+	}
+	if loaded.Responders == nil {
+		loaded.Responders = make(map[string]Entry)
+	}
+	return loaded, nil
+}
+
+func save() error {
+	return utils.SaveFile(path, current, json.Marshal)
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pause
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/testutils"
+)
+
+func TestPause(t *testing.T) {
+	p := testutils.ConfigureFile(t, "pause.json", Configure)
+	if Paused("restart") {
+		t.Errorf("Expected responder not to be paused")
+	}
+	if err := Pause("restart", "127.0.0.1"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !Paused("restart") || Paused("reboot") {
+		t.Errorf("Expected only restart to be paused")
+	}
+	if err := Pause("", "127.0.0.1"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !Paused("reboot") {
+		t.Errorf("Expected all responders to be paused")
+	}
+	if val := testutil.ToFloat64(metrics.Paused.WithLabelValues("")); val != 1 {
+		t.Errorf("Unexpected global paused metric: %v", val)
+	}
+	if val := testutil.ToFloat64(metrics.Paused.WithLabelValues("restart")); val != 1 {
+		t.Errorf("Unexpected responder paused metric: %v", val)
+	}
+
+	// Paused state is loaded from the file
+	if err := Configure(""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	current = State{Responders: make(map[string]Entry)}
+	if err := Configure(p); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := Get()
+	if s.Global == nil || s.Global.By != "127.0.0.1" || len(s.Responders) != 1 {
+		t.Errorf("Unexpected state: %+v", s)
+	}
+
+	// Resuming globally keeps individual pauses
+	if err := Resume(""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if Paused("reboot") || !Paused("restart") {
+		t.Errorf("Expected only restart to be paused")
+	}
+	if err := Resume("restart"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if Paused("restart") {
+		t.Errorf("Expected restart to be resumed")
+	}
+	if val := testutil.ToFloat64(metrics.Paused.WithLabelValues("")); val != 0 {
+		t.Errorf("Unexpected global paused metric: %v", val)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package poll queries the Alertmanager API for alerts as an alternative to receiving webhooks.
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/treydock/alertmanager-command-responder/internal/alert"
+	"github.com/treydock/alertmanager-command-responder/internal/alertmanager"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"github.com/treydock/alertmanager-command-responder/internal/metrics"
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+// Handler handles the alerts that started firing or resolved since the last poll.
+type Handler func(alerts []alert.Alert)
+
+// Poller tracks the firing alerts by fingerprint so alerts are handled once when they start firing and once when they resolve.
+type Poller struct {
+	sc     *config.SafeConfig
+	handle Handler
+	logger log.Logger
+	path   string
+	lock   sync.Mutex
+	alerts map[string]trackedAlert
+	// The filter and receiver of the query the tracked alerts were returned by
+	filter   []string
+	receiver string
+}
+
+type trackedAlert struct {
+	Alert    template.Alert `json:"alert"`
+	Receiver string         `json:"receiver"`
+}
+
+type state struct {
+	Alerts   map[string]trackedAlert `json:"alerts"`
+	Filter   []string                `json:"filter,omitempty"`
+	Receiver string                  `json:"receiver,omitempty"`
+}
+
+// New returns a poller, the tracked alerts are loaded from the state file at path if it exists.
+func New(sc *config.SafeConfig, path string, handle Handler, logger log.Logger) (*Poller, error) {
+	p := &Poller{
+		sc:     sc,
+		handle: handle,
+		logger: log.With(logger, "component", "poll"),
+		path:   path,
+		alerts: make(map[string]trackedAlert),
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	metrics.PollTrackedAlerts.Set(float64(len(p.alerts)))
+	return p, nil
+}
+
+// StateFile returns the path of the file the tracked alerts are saved to.
+func (p *Poller) StateFile() string {
+	return p.path
+}
+
+// Run polls Alertmanager at the configured interval until done is closed.
+func (p *Poller) Run(done <-chan struct{}) {
+	for {
+		interval := p.sc.Config().Alertmanager.Poll.Interval
+		if interval <= 0 {
+			level.Info(p.logger).Log("msg", "Alertmanager polling is disabled")
+			return
+		}
+		_ = p.Poll(context.Background())
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Poll queries Alertmanager once and handles the alerts that changed state.
+// Silenced and inhibited alerts are not handled when they start firing but are still tracked once firing.
+func (p *Poller) Poll(ctx context.Context) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	c := p.sc.Config()
+	client := alertmanager.NewClient(c.Alertmanager)
+	alerts, err := client.Alerts(ctx, c.Alertmanager.Poll.Filter, c.Alertmanager.Poll.Receiver)
+	if err != nil {
+		level.Error(p.logger).Log("msg", "Unable to query Alertmanager alerts", "err", err)
+		metrics.PollErrorsTotal.Inc()
+		return err
+	}
+	receiver, err := regexp.Compile("^(?:" + c.Alertmanager.Poll.Receiver + ")$")
+	if err != nil {
+		return err
+	}
+	// Alerts tracked from a different filter or receiver are not resolved when they are no longer returned
+	requery := !p.sameQuery(c.Alertmanager.Poll)
+	if requery {
+		if len(p.alerts) > 0 {
+			level.Info(p.logger).Log("msg", "Alertmanager poll filter or receiver changed, tracking alerts again")
+		}
+		p.filter = c.Alertmanager.Poll.Filter
+		p.receiver = c.Alertmanager.Poll.Receiver
+	}
+	var changed []alert.Alert
+	seen := make(map[string]bool)
+	for _, a := range alerts {
+		seen[a.Fingerprint] = true
+		if _, ok := p.alerts[a.Fingerprint]; ok || a.Status.State != "active" {
+			continue
+		}
+		tracked := trackedAlert{
+			Alert: template.Alert{
+				Status:       "firing",
+				Labels:       a.Labels,
+				Annotations:  a.Annotations,
+				StartsAt:     a.StartsAt,
+				GeneratorURL: a.GeneratorURL,
+				Fingerprint:  a.Fingerprint,
+			},
+		}
+		for _, r := range a.Receivers {
+			if c.Alertmanager.Poll.Receiver == "" || receiver.MatchString(r.Name) {
+				tracked.Receiver = r.Name
+				break
+			}
+		}
+		p.alerts[a.Fingerprint] = tracked
+		changed = append(changed, tracked.alert(c))
+	}
+	now := time.Now()
+	for fingerprint, tracked := range p.alerts {
+		if seen[fingerprint] {
+			continue
+		}
+		if requery {
+			delete(p.alerts, fingerprint)
+			continue
+		}
+		tracked.Alert.Status = "resolved"
+		tracked.Alert.EndsAt = now
+		delete(p.alerts, fingerprint)
+		changed = append(changed, tracked.alert(c))
+	}
+	metrics.PollTrackedAlerts.Set(float64(len(p.alerts)))
+	if len(changed) == 0 && !requery {
+		return nil
+	}
+	// The state is saved before handling so a restart does not run the same commands again
+	if err := p.save(); err != nil {
+		level.Error(p.logger).Log("msg", "Unable to save poll state", "path", p.path, "err", err)
+		metrics.PollErrorsTotal.Inc()
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	level.Info(p.logger).Log("msg", fmt.Sprintf("Polled %d changed alerts", len(changed)))
+	p.handle(changed)
+	return nil
+}
+
+func (t trackedAlert) alert(c *config.Config) alert.Alert {
+	return alert.Alert{
+		Alert: t.Alert,
+		Source: alert.Source{
+			Receiver:    t.Receiver,
+			ExternalURL: c.Alertmanager.URL,
+		},
+	}
+}
+
+func (p *Poller) load() error {
+	if p.path == "" {
+		return nil
+	}
+	var s state
+	if err := utils.LoadFile(p.path, "poll state", &s, json.Unmarshal); err != nil {
+		return err
+	}
+	p.filter = s.Filter
+	p.receiver = s.Receiver
+	if s.Alerts != nil {
+		p.alerts = s.Alerts
+	}
+	return nil
+}
+
+func (p *Poller) save() error {
+	return utils.SaveFile(p.path, state{Alerts: p.alerts, Filter: p.filter, Receiver: p.receiver}, json.Marshal)
+}
+
+func (p *Poller) sameQuery(c config.PollConfig) bool {
+	if p.receiver != c.Receiver || len(p.filter) != len(c.Filter) {
+		return false
+	}
+	for i := range p.filter {
+		if p.filter[i] != c.Filter[i] {
+			return false
+		}
+	}
+	return true
+}
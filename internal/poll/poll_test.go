@@ -0,0 +1,156 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/treydock/alertmanager-command-responder/internal/alert"
+	"github.com/treydock/alertmanager-command-responder/internal/alertmanager"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+)
+
+func TestPoll(t *testing.T) {
+	var lock sync.Mutex
+	var alerts []alertmanager.Alert
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.URL.Path != "/api/v2/alerts" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.RawQuery
+		_ = json.NewEncoder(w).Encode(alerts)
+	}))
+	defer server.Close()
+	setAlerts := func(a ...alertmanager.Alert) {
+		lock.Lock()
+		alerts = a
+		lock.Unlock()
+	}
+	sc := &config.SafeConfig{}
+	sc.SetConfig(&config.Config{
+		Alertmanager: config.AlertmanagerConfig{
+			URL:     server.URL,
+			Timeout: time.Second,
+			Poll: config.PollConfig{
+				Interval:  time.Minute,
+				Filter:    []string{`severity="critical"`},
+				Receiver:  "responder.*",
+				StateFile: filepath.Join(t.TempDir(), "state.json"),
+			},
+		},
+	})
+	var handled []alert.Alert
+	handle := func(alerts []alert.Alert) {
+		handled = append(handled, alerts...)
+	}
+	p, err := New(sc, sc.Config().Alertmanager.Poll.StateFile, handle, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	firing := alertmanager.Alert{
+		Fingerprint: "a1",
+		Labels:      map[string]string{"alertname": "foo"},
+		Receivers:   []alertmanager.Receiver{{Name: "default"}, {Name: "responder-1"}},
+		Status:      alertmanager.AlertStatus{State: "active"},
+	}
+	silenced := alertmanager.Alert{
+		Fingerprint: "a2",
+		Labels:      map[string]string{"alertname": "bar"},
+		Status:      alertmanager.AlertStatus{State: "suppressed", SilencedBy: []string{"s1"}},
+	}
+	setAlerts(firing, silenced)
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if query != "filter=severity%3D%22critical%22&receiver=responder.%2A" {
+		t.Errorf("Unexpected query: %s", query)
+	}
+	if len(handled) != 1 || handled[0].Fingerprint != "a1" || handled[0].Status != "firing" || handled[0].Source.Receiver != "responder-1" {
+		t.Fatalf("Unexpected handled alerts: %+v", handled)
+	}
+	handled = nil
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(handled) != 0 {
+		t.Errorf("Expected no changes, got %+v", handled)
+	}
+
+	// Alert resolves while a new poller is started from the saved state
+	p, err = New(sc, sc.Config().Alertmanager.Poll.StateFile, handle, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	setAlerts()
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(handled) != 1 || handled[0].Fingerprint != "a1" || handled[0].Status != "resolved" || handled[0].Source.Receiver != "responder-1" {
+		t.Fatalf("Unexpected handled alerts: %+v", handled)
+	}
+	if handled[0].EndsAt.IsZero() {
+		t.Errorf("Expected resolved alert to have ends at")
+	}
+	if len(p.alerts) != 0 {
+		t.Errorf("Unexpected tracked alerts: %v", p.alerts)
+	}
+
+	// Changing the filter tracks alerts again instead of resolving the alerts that no longer match
+	setAlerts(firing)
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handled = nil
+	c := *sc.Config()
+	c.Alertmanager.Poll.Filter = []string{`severity="warning"`}
+	sc.SetConfig(&c)
+	other := firing
+	other.Fingerprint = "a3"
+	setAlerts(other)
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(handled) != 1 || handled[0].Fingerprint != "a3" || handled[0].Status != "firing" {
+		t.Fatalf("Unexpected handled alerts: %+v", handled)
+	}
+	if _, ok := p.alerts["a1"]; ok || len(p.alerts) != 1 {
+		t.Errorf("Unexpected tracked alerts: %v", p.alerts)
+	}
+	handled = nil
+	setAlerts()
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(handled) != 1 || handled[0].Fingerprint != "a3" || handled[0].Status != "resolved" {
+		t.Fatalf("Unexpected handled alerts: %+v", handled)
+	}
+
+	server.Close()
+	if err := p.Poll(context.Background()); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state keeps the firing episodes of alerts so handling an alert can depend on earlier executions.
+// An episode lasts from when an alert starts firing until it resolves.
+// The state is saved to a file when configured so it survives restarts.
+// Episodes of alerts that never resolve, for example because the resolved notification was lost,
+// are pruned once they have had no executions for the configured maximum age.
+package state
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/utils"
+)
+
+var (
+	lock       sync.Mutex
+	configured bool
+	path       string
+	maxAge     time.Duration
+	episodes   = make(map[string]Episode)
+)
+
+// Episode is the firing episode of an alert.
+type Episode struct {
+	Responder  string      `json:"responder"`
+	StartsAt   time.Time   `json:"starts_at"`
+	Executions []Execution `json:"executions"`
+}
+
+// Execution records the commands of a responder running for a firing alert.
+type Execution struct {
+	Time    time.Time `json:"time"`
+	Outcome string    `json:"outcome"`
+	Level   int       `json:"level"`
+}
+
+// Succeeded returns true if any execution of the episode succeeded.
+func (e Episode) Succeeded() bool {
+	for _, execution := range e.Executions {
+		if execution.Outcome == "success" {
+			return true
+		}
+	}
+	return false
+}
+
+// lastActivity returns the time of the last execution of the episode, or when it started if nothing was executed.
+func (e Episode) lastActivity() time.Time {
+	last := e.StartsAt
+	for _, execution := range e.Executions {
+		if execution.Time.After(last) {
+			last = execution.Time
+		}
+	}
+	return last
+}
+
+// LastLevel returns the highest escalation level executed during the episode, -1 if nothing was executed.
+func (e Episode) LastLevel() int {
+	last := -1
+	for _, execution := range e.Executions {
+		if execution.Level > last {
+			last = execution.Level
+		}
+	}
+	return last
+}
+
+// Configure loads the state from the file, with an empty path the state is only kept in memory.
+// Episodes without executions for longer than age are pruned when the state is saved, a zero age keeps them.
+func Configure(p string, age time.Duration) error {
+	lock.Lock()
+	defer lock.Unlock()
+	maxAge = age
+	if configured && p == path {
+		return nil
+	}
+	loaded := episodes
+	if p != "" {
+		var err error
+		loaded, err = load(p)
+		if err != nil {
+			return err
+		}
+	}
+	configured = true
+	path = p
+	episodes = loaded
+	return nil
+}
+
+// Get returns the episode of the alert with the fingerprint.
+func Get(fingerprint string) (Episode, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	e, ok := episodes[fingerprint]
+	return e, ok
+}
+
+// RecordExecution adds the execution to the episode of the alert.
+// A new episode is started when the alert started firing at a different time than the current episode.
+func RecordExecution(fingerprint string, responder string, startsAt time.Time, execution Execution) error {
+	lock.Lock()
+	defer lock.Unlock()
+	e, ok := episodes[fingerprint]
+	if !ok || !e.StartsAt.Equal(startsAt) {
+		e = Episode{Responder: responder, StartsAt: startsAt}
+	}
+	e.Executions = append(e.Executions, execution)
+	episodes[fingerprint] = e
+	return save()
+}
+
+// EndEpisode removes the episode of the alert once it resolves.
+func EndEpisode(fingerprint string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := episodes[fingerprint]; !ok {
+		return nil
+	}
+	delete(episodes, fingerprint)
+	return save()
+}
+
+func load(p string) (map[string]Episode, error) {
+	loaded := make(map[string]Episode)
+	if err := utils.LoadFile(p, "state", &loaded, json.Unmarshal); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+func save() error {
+	prune(time.Now())
+	return utils.SaveFile(path, episodes, json.Marshal)
+}
+
+// prune removes the episodes without executions for longer than the maximum age.
+func prune(now time.Time) {
+	if maxAge <= 0 {
+		return
+	}
+	for fingerprint, e := range episodes {
+		if now.Sub(e.lastActivity()) > maxAge {
+			delete(episodes, fingerprint)
+		}
+	}
+}
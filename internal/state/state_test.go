@@ -0,0 +1,122 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/treydock/alertmanager-command-responder/internal/testutils"
+)
+
+func configure(p string) error {
+	return Configure(p, 0)
+}
+
+func TestState(t *testing.T) {
+	p := testutils.ConfigureFile(t, "state.json", configure)
+	startsAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := RecordExecution("fp1", "restart", startsAt, Execution{Time: time.Now(), Outcome: "failure"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	e, ok := Get("fp1")
+	if !ok || e.Responder != "restart" || len(e.Executions) != 1 || e.Succeeded() {
+		t.Errorf("Unexpected episode: %+v", e)
+	}
+	if err := RecordExecution("fp1", "restart", startsAt, Execution{Time: time.Now(), Outcome: "success"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if e, _ := Get("fp1"); len(e.Executions) != 2 || !e.Succeeded() || e.LastLevel() != 0 {
+		t.Errorf("Unexpected episode: %+v", e)
+	}
+	if last := (Episode{}).LastLevel(); last != -1 {
+		t.Errorf("Unexpected last level: %d", last)
+	}
+
+	// State is loaded from the file
+	if err := configure(""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	episodes = make(map[string]Episode)
+	if err := configure(p); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	e, ok = Get("fp1")
+	if !ok || len(e.Executions) != 2 || !e.StartsAt.Equal(startsAt) {
+		t.Errorf("Unexpected episode: %+v", e)
+	}
+
+	// A new firing episode replaces the old one
+	if err := RecordExecution("fp1", "restart", startsAt.Add(time.Hour), Execution{Time: time.Now(), Outcome: "timeout"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if e, _ := Get("fp1"); len(e.Executions) != 1 || e.Succeeded() {
+		t.Errorf("Unexpected episode: %+v", e)
+	}
+	if err := EndEpisode("fp1"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := Get("fp1"); ok {
+		t.Errorf("Expected episode to be removed")
+	}
+	buffer, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buffer) != "{}" {
+		t.Errorf("Unexpected state file: %s", buffer)
+	}
+
+	if err := configure(testutils.WriteFile(t, "invalid.json", "{")); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	p := testutils.ConfigureFile(t, "state.json", func(p string) error { return Configure(p, time.Hour) })
+	old := time.Now().Add(-3 * time.Hour)
+	// The execution is recent so the episode is kept even though it started long ago
+	if err := RecordExecution("active", "restart", old, Execution{Time: time.Now().Add(-time.Minute), Outcome: "failure"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := RecordExecution("stale", "restart", old, Execution{Time: old, Outcome: "failure"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := Get("stale"); ok {
+		t.Errorf("Expected stale episode to be pruned")
+	}
+	if _, ok := Get("active"); !ok {
+		t.Errorf("Expected active episode to be kept")
+	}
+	buffer, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buffer), "stale") {
+		t.Errorf("Unexpected state file: %s", buffer)
+	}
+
+	// A zero age keeps every episode
+	if err := Configure(p, 0); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := RecordExecution("stale", "restart", old, Execution{Time: old, Outcome: "failure"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := Get("stale"); !ok {
+		t.Errorf("Expected stale episode to be kept")
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutils contains helpers shared by the tests of packages that persist state to a file.
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ConfigureFile configures a package with a file named name in a temporary directory
+// and unconfigures the file when the test ends. The path of the file is returned.
+func ConfigureFile(t *testing.T, name string, configure func(string) error) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := configure(p); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = configure("")
+	})
+	return p
+}
+
+// WriteFile writes the contents to a file named name in a temporary directory and returns the path of the file.
+func WriteFile(t *testing.T, name string, contents string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing configures OpenTelemetry tracing exported using OTLP.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/common/version"
+	"github.com/treydock/alertmanager-command-responder/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName    = "alertmanager-command-responder"
+	instrumentName = "github.com/treydock/alertmanager-command-responder"
+)
+
+var (
+	providerLock sync.Mutex
+	provider     *sdktrace.TracerProvider
+)
+
+// Tracer returns the tracer used for all spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentName)
+}
+
+// Configure replaces the tracer provider with one exporting to the configured endpoint.
+// Tracing is disabled if no endpoint is configured.
+func Configure(c config.TracingConfig) error {
+	var newProvider *sdktrace.TracerProvider
+	if c.Endpoint != "" {
+		headers := make(map[string]string)
+		for k, v := range c.Headers {
+			headers[k] = string(v)
+		}
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(c.Endpoint),
+			otlptracehttp.WithHeaders(headers),
+			otlptracehttp.WithTimeout(c.Timeout),
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return fmt.Errorf("Unable to create OTLP trace exporter: %v", err)
+		}
+		res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version.Version),
+		))
+		if err != nil {
+			return fmt.Errorf("Unable to create tracing resource: %v", err)
+		}
+		newProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SamplingFraction))),
+		)
+	}
+
+	providerLock.Lock()
+	oldProvider := provider
+	provider = newProvider
+	providerLock.Unlock()
+	if newProvider != nil {
+		otel.SetTracerProvider(newProvider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	} else if oldProvider != nil {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	}
+	if oldProvider != nil {
+		return oldProvider.Shutdown(context.Background())
+	}
+	return nil
+}
+
+// Shutdown flushes pending spans and disables tracing.
+func Shutdown() error {
+	return Configure(config.TracingConfig{})
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadFile reads the file at path and unmarshals it into v, for example with json.Unmarshal.
+// A missing file leaves v unchanged. The description names the file in errors.
+func LoadFile(path string, description string, v interface{}, unmarshal func([]byte, interface{}) error) error {
+	buffer, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Unable to read %s %s: %v", description, path, err)
+	}
+	if err := unmarshal(buffer, v); err != nil {
+		return fmt.Errorf("Unable to parse %s %s: %v", description, path, err)
+	}
+	return nil
+}
+
+// SaveFile marshals v, for example with json.Marshal, and writes it to a temporary file
+// that replaces the file at path so it is never partially written. An empty path is not saved.
+func SaveFile(path string, v interface{}, marshal func(interface{}) ([]byte, error)) error {
+	if path == "" {
+		return nil
+	}
+	buffer, err := marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buffer, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
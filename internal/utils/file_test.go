@@ -0,0 +1,53 @@
+// Copyright 2022 Trey Dockendorf
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/treydock/alertmanager-command-responder/internal/testutils"
+)
+
+func TestSaveFileLoadFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.json")
+	loaded := map[string]int{"default": 1}
+	if err := LoadFile(p, "test file", &loaded, json.Unmarshal); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(loaded) != 1 || loaded["default"] != 1 {
+		t.Errorf("Expected missing file to leave value unchanged, got %v", loaded)
+	}
+	if err := SaveFile(p, map[string]int{"foo": 2}, json.Marshal); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := os.Stat(p + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected temporary file to be renamed")
+	}
+	loaded = make(map[string]int)
+	if err := LoadFile(p, "test file", &loaded, json.Unmarshal); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(loaded) != 1 || loaded["foo"] != 2 {
+		t.Errorf("Unexpected loaded value: %v", loaded)
+	}
+	if err := SaveFile("", loaded, json.Marshal); err != nil {
+		t.Errorf("Unexpected error saving without a path: %s", err)
+	}
+	if err := LoadFile(testutils.WriteFile(t, "invalid.json", "{"), "test file", &loaded, json.Unmarshal); err == nil {
+		t.Errorf("Expected an error")
+	}
+}